@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/aryadhira/reseller-management/internal/docs"
@@ -10,7 +11,9 @@ import (
 
 	"github.com/aryadhira/reseller-management/internal/config"
 	"github.com/aryadhira/reseller-management/internal/database"
+	"github.com/aryadhira/reseller-management/internal/jobs"
 	"github.com/aryadhira/reseller-management/internal/middleware"
+	"github.com/aryadhira/reseller-management/internal/repository"
 	"github.com/aryadhira/reseller-management/internal/routes"
 )
 
@@ -24,6 +27,12 @@ func main() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Flag orders past their due date as overdue
+	go jobs.NewOverdueChecker(repository.NewRepository(db).Payment, cfg.OverdueCheck).Run(context.Background())
+
+	// Remove idempotency keys past their retention window
+	go jobs.NewIdempotencyKeyPurger(repository.NewIdempotencyKeyRepository(db), cfg.IdempotencyTTL).Run(context.Background())
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
@@ -41,7 +50,7 @@ func main() {
 	app.Use(cors.New())
 
 	// Setup routes
-	routes.SetupRoutes(app, db)
+	routes.SetupRoutes(app, db, cfg)
 
 	// Setup Swagger documentation
 	docs.RegisterSwaggerRoutes(app)
@@ -0,0 +1,111 @@
+// Package bankstatement reads bank statement exports and proposes which open
+// order each incoming transfer pays. Every bank layout has its own parser,
+// registered under the name clients pick when uploading a statement.
+package bankstatement
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a single transaction read from a bank statement
+type Entry struct {
+	// Date the bank booked the transaction
+	Date time.Time
+	// Free text the bank shows, usually with the sender and their message
+	Description string
+	// Bank reference of the transaction, when the layout has one
+	Reference string
+	// Signed amount, positive for money coming in
+	Amount float64
+}
+
+// Fingerprint identifies an entry of a bank's statements, so a transfer is
+// recognised when a statement is imported again or two statement periods
+// overlap. Identical entries on one statement are told apart by their
+// occurrence, counting from one.
+func Fingerprint(bank string, entry Entry, occurrence int) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s|%s|%.2f|%s|%s|%d",
+		strings.ToLower(bank),
+		entry.Date.Format("2006-01-02"),
+		entry.Amount,
+		normalize(entry.Description),
+		normalize(entry.Reference),
+		occurrence,
+	)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Parser reads the entries of one bank's statement layout
+type Parser interface {
+	Parse(r io.Reader) ([]Entry, error)
+}
+
+var (
+	mu      sync.RWMutex
+	parsers = map[string]Parser{
+		"generic": GenericCSV{},
+		"bca":     BCACSV{},
+	}
+)
+
+// Register makes a parser available under name, replacing any parser
+// registered under the same name
+func Register(name string, parser Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+	parsers[strings.ToLower(name)] = parser
+}
+
+// Lookup returns the parser registered under name
+func Lookup(name string) (Parser, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	parser, ok := parsers[strings.ToLower(name)]
+	return parser, ok
+}
+
+// Names lists the registered parsers in alphabetical order
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseAmount reads an amount written with comma thousand separators and a
+// dot for decimals, such as "1,500,000.00"
+func parseAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "Rp")
+	value = strings.ReplaceAll(value, ",", "")
+	value = strings.ReplaceAll(value, " ", "")
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
+
+// parseDate reads a date in one of the layouts
+func parseDate(value string, layouts ...string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range layouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,35 @@
+package bankstatement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	entry := Entry{
+		Date:        time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local),
+		Description: "TRSF E-BANKING CR ORD-2026-000042",
+		Reference:   "REF-1",
+		Amount:      500,
+	}
+
+	same := entry
+	same.Description = "trsf e-banking  cr ord-2026-000042"
+	assert.Equal(t, Fingerprint("bca", entry, 1), Fingerprint("BCA", same, 1))
+
+	otherAmount := entry
+	otherAmount.Amount = 501
+	otherDate := entry
+	otherDate.Date = entry.Date.AddDate(0, 0, 1)
+
+	fingerprints := map[string]bool{
+		Fingerprint("bca", entry, 1):       true,
+		Fingerprint("bca", entry, 2):       true,
+		Fingerprint("generic", entry, 1):   true,
+		Fingerprint("bca", otherAmount, 1): true,
+		Fingerprint("bca", otherDate, 1):   true,
+	}
+	assert.Len(t, fingerprints, 5)
+}
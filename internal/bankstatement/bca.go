@@ -0,0 +1,126 @@
+package bankstatement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// BCACSV reads the CSV mutation export of BCA internet banking. The export
+// starts with account details, including the statement period, followed by
+// the columns Tanggal Transaksi, Keterangan, Cabang, Jumlah and Saldo, and
+// ends with the opening and closing balance. Dates are written without a
+// year, which is taken from the period. Pending transactions are skipped.
+type BCACSV struct{}
+
+func (BCACSV) Parse(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var (
+		entries []Entry
+		period  time.Time
+		inRows  bool
+	)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Blank lines are skipped, so errors name the line in the file
+		line, _ := reader.FieldPos(0)
+
+		first := strings.TrimSpace(strings.TrimPrefix(record[0], "'"))
+		if !inRows {
+			if strings.HasPrefix(first, "Periode") {
+				period = bcaPeriodStart(strings.Join(record, ","))
+			}
+			inRows = strings.HasPrefix(first, "Tanggal")
+			continue
+		}
+
+		switch {
+		case first == "" || first == "PEND":
+			continue
+		case strings.HasPrefix(first, "Saldo") || strings.HasPrefix(first, "Mutasi"):
+			// The footer with balances ends the transactions
+			return entries, nil
+		}
+
+		if len(record) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least 4 columns", line)
+		}
+
+		date, err := bcaDate(first, period)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		value := strings.TrimSpace(record[3])
+		direction := ""
+		if fields := strings.Fields(value); len(fields) == 2 {
+			value, direction = fields[0], fields[1]
+		} else if len(record) > 4 {
+			direction = strings.TrimSpace(record[4])
+		}
+		amount, err := parseAmount(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if strings.EqualFold(direction, "DB") {
+			amount = -amount
+		}
+
+		entries = append(entries, Entry{
+			Date:        date,
+			Description: strings.Join(strings.Fields(record[1]), " "),
+			Amount:      amount,
+		})
+	}
+
+	if !inRows {
+		return nil, errors.New("missing Tanggal Transaksi header")
+	}
+	return entries, nil
+}
+
+// bcaPeriodStart reads the first date of a "Periode : 01/01/2026 - 31/01/2026"
+// line, or returns the zero time when it cannot be read
+func bcaPeriodStart(line string) time.Time {
+	_, dates, ok := strings.Cut(line, ":")
+	if !ok {
+		return time.Time{}
+	}
+	start, _, _ := strings.Cut(dates, "-")
+	date, err := parseDate(strings.Trim(start, " ,"), "02/01/2006")
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// bcaDate reads a dd/mm transaction date within the statement period. A
+// period spanning the new year puts months before the start in the next year.
+func bcaDate(value string, period time.Time) (time.Time, error) {
+	if period.IsZero() {
+		period = time.Now()
+	}
+
+	date, err := parseDate(value, "02/01")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	year := period.Year()
+	if date.Month() < period.Month() {
+		year++
+	}
+	return time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, time.Local), nil
+}
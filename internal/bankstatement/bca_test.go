@@ -0,0 +1,79 @@
+package bankstatement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const bcaHeader = "Informasi Rekening - Mutasi Rekening\n" +
+	"No. rekening : ,'1234567890\n" +
+	"Nama : ,TOKO ANDA\n" +
+	"Periode : ,20/12/2025 - 10/01/2026\n" +
+	"Kode Mata Uang : ,IDR\n" +
+	"\n" +
+	"Tanggal Transaksi,Keterangan,Cabang,Jumlah,,Saldo\n"
+
+func TestBCACSVParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			name: "transactions across the new year",
+			csv: bcaHeader +
+				"'30/12,TRSF E-BANKING CR 3012/FTSCY/WS95031  ORD-2025-000042 TOKO MAJU,'0000,\"500,000.00\",CR,\"1,500,000.00\"\n" +
+				"PEND,TRSF E-BANKING CR PENDING,'0000,100.00,CR,\n" +
+				"'02/01,BIAYA ADM,'0000,10000.00 DB,,\"1,490,000.00\"\n" +
+				"'03/01,SETORAN TUNAI,'0998,250000.00 CR,,\"1,740,000.00\"\n" +
+				"Saldo Awal,\"1,000,000.00\"\n" +
+				"Mutasi Kredit,\"750,000.00\"\n" +
+				"'04/01,AFTER THE FOOTER,'0000,1.00,CR,\n",
+			want: []Entry{
+				{Date: time.Date(2025, 12, 30, 0, 0, 0, 0, time.Local), Description: "TRSF E-BANKING CR 3012/FTSCY/WS95031 ORD-2025-000042 TOKO MAJU", Amount: 500000},
+				{Date: time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local), Description: "BIAYA ADM", Amount: -10000},
+				{Date: time.Date(2026, 1, 3, 0, 0, 0, 0, time.Local), Description: "SETORAN TUNAI", Amount: 250000},
+			},
+		},
+		{
+			name: "no transactions",
+			csv:  bcaHeader + "Saldo Awal,\"1,000,000.00\"\n",
+		},
+		{
+			name:    "missing header",
+			csv:     "Informasi Rekening - Mutasi Rekening\n'30/12,TRSF,'0000,100.00,CR,\n",
+			wantErr: "missing Tanggal Transaksi header",
+		},
+		{
+			name:    "too few columns",
+			csv:     bcaHeader + "'30/12,TRSF,'0000\n",
+			wantErr: "line 8: expected at least 4 columns",
+		},
+		{
+			name:    "invalid date",
+			csv:     bcaHeader + "'31/02,TRSF,'0000,100.00,CR,\n",
+			wantErr: `line 8: invalid date "31/02"`,
+		},
+		{
+			name:    "invalid amount",
+			csv:     bcaHeader + "'30/12,TRSF,'0000,1OO.00,CR,\n",
+			wantErr: `line 8: invalid amount "1OO.00"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := BCACSV{}.Parse(strings.NewReader(test.csv))
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, entries)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package bankstatement
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenericCSV reads a CSV file with a header row naming its columns. The
+// date, description and amount columns are required, reference and type are
+// optional. Rows with a type of debit, db or d are read as money going out.
+type GenericCSV struct{}
+
+func (GenericCSV) Parse(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"date", "description", "amount"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var entries []Entry
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Blank lines are skipped, so errors name the line in the file
+		line, _ := reader.FieldPos(0)
+
+		date, err := parseDate(field(record, "date"), "2006-01-02", "02/01/2006", "2006-01-02 15:04:05")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		amount, err := parseAmount(field(record, "amount"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch strings.ToLower(field(record, "type")) {
+		case "debit", "db", "d":
+			amount = -amount
+		}
+
+		entries = append(entries, Entry{
+			Date:        date,
+			Description: field(record, "description"),
+			Reference:   field(record, "reference"),
+			Amount:      amount,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+package bankstatement
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericCSVParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			name: "credits and debits",
+			csv: "Date,Description,Amount,Reference,Type\n" +
+				"2026-01-05,TRF ORD-2026-000042,\"1,500,000.00\",REF-1,credit\n" +
+				"05/01/2026,ATM WITHDRAWAL,50000,REF-2,DB\n" +
+				"2026-01-06 13:45:00,CARD FEE,Rp 2500,,d\n",
+			want: []Entry{
+				{Date: time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local), Description: "TRF ORD-2026-000042", Reference: "REF-1", Amount: 1500000},
+				{Date: time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local), Description: "ATM WITHDRAWAL", Reference: "REF-2", Amount: -50000},
+				{Date: time.Date(2026, 1, 6, 13, 45, 0, 0, time.Local), Description: "CARD FEE", Amount: -2500},
+			},
+		},
+		{
+			name: "optional columns left out",
+			csv:  "description,amount,date\nTRANSFER FROM TOKO MAJU,200.50,2026-02-01\n",
+			want: []Entry{
+				{Date: time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local), Description: "TRANSFER FROM TOKO MAJU", Amount: 200.5},
+			},
+		},
+		{
+			name: "header only",
+			csv:  "date,description,amount\n",
+		},
+		{
+			name:    "empty file",
+			csv:     "",
+			wantErr: "reading header",
+		},
+		{
+			name:    "missing amount column",
+			csv:     "date,description,value\n2026-01-05,TRF,100\n",
+			wantErr: "missing amount column",
+		},
+		{
+			name:    "invalid date",
+			csv:     "date,description,amount\n2026-13-45,TRF,100\n",
+			wantErr: `line 2: invalid date "2026-13-45"`,
+		},
+		{
+			name:    "invalid amount",
+			csv:     "date,description,amount\n2026-01-05,TRF,100\n2026-01-05,TRF,ten\n",
+			wantErr: `line 3: invalid amount "ten"`,
+		},
+		{
+			name:    "broken quoting",
+			csv:     "date,description,amount\n\n2026-01-05,TR\"F,100\n",
+			wantErr: "parse error on line 3",
+		},
+		{
+			name:    "short row",
+			csv:     "date,description,amount\n2026-01-05,TRF\n",
+			wantErr: `line 2: invalid amount ""`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries, err := GenericCSV{}.Parse(strings.NewReader(test.csv))
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, entries)
+		})
+	}
+}
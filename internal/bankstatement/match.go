@@ -0,0 +1,114 @@
+package bankstatement
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Points given for each kind of evidence that an entry pays an order
+const (
+	scoreOrderNumber  = 50
+	scoreAmount       = 30
+	scoreResellerName = 20
+
+	// MinScore is the score an order needs before it is proposed, either its
+	// order or invoice number, or both its amount and reseller name
+	MinScore = 50
+)
+
+// Candidate is an open order that a statement entry may pay
+type Candidate struct {
+	OrderID       string
+	OrderNumber   string
+	InvoiceNumber string
+	ResellerName  string
+	// Amount still to be paid on the order
+	Remaining float64
+}
+
+// Match is an order proposed for a statement entry
+type Match struct {
+	Candidate
+	Score   int
+	Reasons []string
+}
+
+// Propose returns the candidate an incoming entry most likely pays. Entries
+// are matched on the order or invoice number in the description, the amount
+// left to pay and the reseller name in the description. On equal scores the
+// earlier candidate wins, so candidates should be sorted oldest due first.
+func Propose(entry Entry, candidates []Candidate) (Match, bool) {
+	if entry.Amount <= 0 {
+		return Match{}, false
+	}
+
+	description := normalize(entry.Description + " " + entry.Reference)
+
+	var best Match
+	for _, candidate := range candidates {
+		match := Match{Candidate: candidate}
+		if containsNumber(description, candidate.OrderNumber) || containsNumber(description, candidate.InvoiceNumber) {
+			match.Score += scoreOrderNumber
+			match.Reasons = append(match.Reasons, "order number")
+		}
+		if math.Abs(entry.Amount-candidate.Remaining) < 0.005 {
+			match.Score += scoreAmount
+			match.Reasons = append(match.Reasons, "amount")
+		}
+		if containsWords(description, candidate.ResellerName) {
+			match.Score += scoreResellerName
+			match.Reasons = append(match.Reasons, "reseller name")
+		}
+
+		if match.Score > best.Score {
+			best = match
+		}
+	}
+
+	return best, best.Score >= MinScore
+}
+
+// containsNumber reports whether a document number such as ORD-2026-000042
+// appears as whole words in the normalized text. Banks often drop the dashes,
+// so consecutive words are joined before comparing. A longer number such as
+// ORD-2026-0000421 does not contain ORD-2026-000042.
+func containsNumber(text, number string) bool {
+	want := strings.ReplaceAll(normalize(number), " ", "")
+	if want == "" {
+		return false
+	}
+
+	words := strings.Fields(text)
+	for i := range words {
+		joined := ""
+		for _, word := range words[i:] {
+			joined += word
+			if len(joined) >= len(want) {
+				break
+			}
+		}
+		if joined == want {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWords reports whether a name appears as whole words in the
+// normalized text, so TOKO MAJU is not found in TOKO MAJUJAYA
+func containsWords(text, name string) bool {
+	name = normalize(name)
+	if name == "" {
+		return false
+	}
+	return strings.Contains(" "+text+" ", " "+name+" ")
+}
+
+// normalize upper-cases text and turns everything but letters and digits
+// into single spaces
+func normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToUpper(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
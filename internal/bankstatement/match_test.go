@@ -0,0 +1,130 @@
+package bankstatement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropose(t *testing.T) {
+	candidates := []Candidate{
+		{OrderID: "a", OrderNumber: "ORD-2026-100000", ResellerName: "Toko Maju", Remaining: 150},
+		{OrderID: "b", OrderNumber: "ORD-2026-1000000", InvoiceNumber: "INV-2026-000007", ResellerName: "Toko Maju Jaya", Remaining: 80},
+		{OrderID: "c", OrderNumber: "ORD-2026-000003", ResellerName: "Berkah", Remaining: 80},
+	}
+
+	tests := []struct {
+		name        string
+		entry       Entry
+		wantOK      bool
+		wantOrderID string
+		wantReasons []string
+	}{
+		{
+			name:        "order number with dashes",
+			entry:       Entry{Description: "TRSF E-BANKING CR ORD-2026-100000", Amount: 10},
+			wantOK:      true,
+			wantOrderID: "a",
+			wantReasons: []string{"order number"},
+		},
+		{
+			name:        "order number without dashes",
+			entry:       Entry{Description: "PAYMENT ORD2026100000", Amount: 10},
+			wantOK:      true,
+			wantOrderID: "a",
+			wantReasons: []string{"order number"},
+		},
+		{
+			name:        "longer order number containing a shorter one",
+			entry:       Entry{Description: "PAYMENT ORD-2026-1000000", Amount: 10},
+			wantOK:      true,
+			wantOrderID: "b",
+			wantReasons: []string{"order number"},
+		},
+		{
+			name:        "longer order number without dashes",
+			entry:       Entry{Description: "PAYMENT ORD20261000000", Amount: 10},
+			wantOK:      true,
+			wantOrderID: "b",
+			wantReasons: []string{"order number"},
+		},
+		{
+			name:   "number followed by more digits",
+			entry:  Entry{Description: "PAYMENT ORD-2026-0000031", Amount: 10},
+			wantOK: false,
+		},
+		{
+			name:        "invoice number in the reference",
+			entry:       Entry{Description: "TRANSFER", Reference: "INV/2026/000007", Amount: 80},
+			wantOK:      true,
+			wantOrderID: "b",
+			wantReasons: []string{"order number", "amount"},
+		},
+		{
+			name:        "amount and reseller name",
+			entry:       Entry{Description: "TRANSFER FROM TOKO MAJU", Amount: 150},
+			wantOK:      true,
+			wantOrderID: "a",
+			wantReasons: []string{"amount", "reseller name"},
+		},
+		{
+			name:        "longer reseller name",
+			entry:       Entry{Description: "TRANSFER FROM TOKO MAJU JAYA", Amount: 80},
+			wantOK:      true,
+			wantOrderID: "b",
+			wantReasons: []string{"amount", "reseller name"},
+		},
+		{
+			name:   "name inside a longer word",
+			entry:  Entry{Description: "TRANSFER FROM TOKO MAJUMUNDUR", Amount: 150},
+			wantOK: false,
+		},
+		{
+			name:   "amount only",
+			entry:  Entry{Description: "UNKNOWN SENDER", Amount: 150},
+			wantOK: false,
+		},
+		{
+			name:        "equal scores pick the earlier candidate",
+			entry:       Entry{Description: "TRANSFER FROM BERKAH TOKO MAJU JAYA", Amount: 80},
+			wantOK:      true,
+			wantOrderID: "b",
+			wantReasons: []string{"amount", "reseller name"},
+		},
+		{
+			name:   "money going out",
+			entry:  Entry{Description: "ORD-2026-100000", Amount: -150},
+			wantOK: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, ok := Propose(test.entry, candidates)
+			assert.Equal(t, test.wantOK, ok)
+			if test.wantOK {
+				assert.Equal(t, test.wantOrderID, match.OrderID)
+				assert.Equal(t, test.wantReasons, match.Reasons)
+			}
+		})
+	}
+}
+
+func TestContainsNumber(t *testing.T) {
+	tests := []struct {
+		text   string
+		number string
+		want   bool
+	}{
+		{"CR ORD 2026 000042 TOKO", "ORD-2026-000042", true},
+		{"CR ORD2026000042 TOKO", "ORD-2026-000042", true},
+		{"CRORD2026000042", "ORD-2026-000042", false},
+		{"ORD 2026 0000421", "ORD-2026-000042", false},
+		{"ORD 2026 000042", "", false},
+		{"", "ORD-2026-000042", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, containsNumber(test.text, test.number), "%q in %q", test.number, test.text)
+	}
+}
@@ -3,22 +3,27 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	AppHost    string
-	AppPort    string
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBSSLMode  string
-	JWTSecret  string
-	JWTExpired time.Duration
+	AppHost        string
+	AppPort        string
+	DBHost         string
+	DBPort         string
+	DBUser         string
+	DBPassword     string
+	DBName         string
+	DBSSLMode      string
+	JWTSecret      string
+	JWTExpired     time.Duration
+	RefreshExpired time.Duration
+	TaxRate        float64 // Percentage added to orders as tax, e.g. 11 for PPN
+	OverdueCheck   time.Duration
+	IdempotencyTTL time.Duration // How long responses to requests with an Idempotency-Key are replayed
 }
 
 func LoadConfig() *Config {
@@ -39,16 +44,20 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		AppHost:    getEnvOrDefault("APP_HOST", "localhost"),
-		AppPort:    getEnvOrDefault("APP_PORT", "localhost"),
-		DBHost:     getEnvOrDefault("DB_HOST", "localhost"),
-		DBPort:     getEnvOrDefault("DB_PORT", "5432"),
-		DBUser:     getEnvOrDefault("DB_USER", "postgres"),
-		DBPassword: getEnvOrDefault("DB_PASSWORD", ""),
-		DBName:     getEnvOrDefault("DB_NAME", "reseller_management"),
-		DBSSLMode:  getEnvOrDefault("DB_SSL_MODE", "disable"),
-		JWTSecret:  getEnvOrDefault("TOKEN_SECRET", "your-secret-key"),
-		JWTExpired: tokenExpired,
+		AppHost:        getEnvOrDefault("APP_HOST", "localhost"),
+		AppPort:        getEnvOrDefault("APP_PORT", "localhost"),
+		DBHost:         getEnvOrDefault("DB_HOST", "localhost"),
+		DBPort:         getEnvOrDefault("DB_PORT", "5432"),
+		DBUser:         getEnvOrDefault("DB_USER", "postgres"),
+		DBPassword:     getEnvOrDefault("DB_PASSWORD", ""),
+		DBName:         getEnvOrDefault("DB_NAME", "reseller_management"),
+		DBSSLMode:      getEnvOrDefault("DB_SSL_MODE", "disable"),
+		JWTSecret:      getEnvOrDefault("TOKEN_SECRET", "your-secret-key"),
+		JWTExpired:     tokenExpired,
+		RefreshExpired: getDurationOrDefault("REFRESH_TOKEN_EXPIRED", 7*24*time.Hour),
+		TaxRate:        getFloatOrDefault("TAX_RATE", 0),
+		OverdueCheck:   getDurationOrDefault("OVERDUE_CHECK_INTERVAL", time.Hour),
+		IdempotencyTTL: getDurationOrDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
 	}
 }
 
@@ -58,3 +67,32 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getFloatOrDefault(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		log.Printf("Invalid number for %s, using default %v", key, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+func getDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	// Intervals and lifetimes must be positive, a ticker panics otherwise
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration for %s, using default %s", key, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const auditStateKey = "audit:before"
+
+// auditIgnoredTables lists tables whose changes are not audited, because they
+// hold credentials, are internal bookkeeping or are the audit log itself
+var auditIgnoredTables = map[string]bool{
+	"audit_logs":       true,
+	"idempotency_keys": true,
+	"sequences":        true,
+	"sessions":         true,
+	"tenants":          true,
+}
+
+// auditIgnoredFields are left out of the change set of updates
+var auditIgnoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// RegisterAuditCallbacks records every create, update and delete made through
+// GORM in the audit log, together with the actor stored in the statement
+// context and the state of the entity before and after the change. Entries are
+// written on the same connection, so they are committed or rolled back with
+// the change itself.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("audit:create", auditCreate); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("audit:capture_update", captureAuditState); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("audit:update", auditUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("audit:capture_delete", captureAuditState); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("audit:delete", auditDelete)
+}
+
+func auditable(db *gorm.DB) bool {
+	if db.Error != nil || db.DryRun || db.Statement.Schema == nil {
+		return false
+	}
+	if db.Statement.Schema.PrioritizedPrimaryField == nil || auditIgnoredTables[db.Statement.Schema.Table] {
+		return false
+	}
+	return !utils.AuditSkipped(db.Statement.Context)
+}
+
+func auditCreate(db *gorm.DB) {
+	if !auditable(db) {
+		return
+	}
+
+	var entries []models.AuditLog
+	forEachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+		entries = append(entries, newAuditLog(db, models.AuditActionCreate, row, nil, snapshot(db, row)))
+	})
+	writeAuditLogs(db, entries)
+}
+
+// captureAuditState loads the rows matched by an update or delete before
+// the statement runs, so the previous values can be recorded afterwards
+func captureAuditState(db *gorm.DB) {
+	if !auditable(db) {
+		return
+	}
+
+	var exprs []clause.Expression
+	if where, ok := db.Statement.Clauses["WHERE"]; ok {
+		if w, ok := where.Expression.(clause.Where); ok {
+			exprs = append(exprs, w.Exprs...)
+		}
+	}
+
+	// Save and Delete(&record) identify the row through its primary key
+	if ids := primaryKeys(db, db.Statement.ReflectValue); len(ids) > 0 {
+		exprs = append(exprs, clause.IN{Column: clause.PrimaryColumn, Values: ids})
+	}
+
+	if len(exprs) == 0 {
+		return
+	}
+
+	rows, err := loadRows(db, exprs)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(auditStateKey, rows)
+}
+
+func auditUpdate(db *gorm.DB) {
+	before, ok := capturedRows(db)
+	if !ok || before.Len() == 0 {
+		return
+	}
+
+	after, err := loadRows(db, []clause.Expression{
+		clause.IN{Column: clause.PrimaryColumn, Values: primaryKeys(db, before)},
+	})
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+
+	afterByID := make(map[string]reflect.Value, after.Len())
+	forEachRow(after, func(row reflect.Value) {
+		afterByID[entityID(db, row)] = row
+	})
+
+	var entries []models.AuditLog
+	forEachRow(before, func(row reflect.Value) {
+		updated, ok := afterByID[entityID(db, row)]
+		if !ok {
+			return
+		}
+
+		entry := newAuditLog(db, models.AuditActionUpdate, row, snapshot(db, row), snapshot(db, updated))
+		entry.Changes = diff(entry.Before, entry.After)
+		if len(entry.Changes) > 0 {
+			entries = append(entries, entry)
+		}
+	})
+	writeAuditLogs(db, entries)
+}
+
+func auditDelete(db *gorm.DB) {
+	before, ok := capturedRows(db)
+	if !ok {
+		return
+	}
+
+	var entries []models.AuditLog
+	forEachRow(before, func(row reflect.Value) {
+		entries = append(entries, newAuditLog(db, models.AuditActionDelete, row, snapshot(db, row), nil))
+	})
+	writeAuditLogs(db, entries)
+}
+
+func capturedRows(db *gorm.DB) (reflect.Value, bool) {
+	if !auditable(db) {
+		return reflect.Value{}, false
+	}
+	value, ok := db.InstanceGet(auditStateKey)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	rows, ok := value.(reflect.Value)
+	return rows, ok
+}
+
+// loadRows returns a slice of the statement's model matching exprs, within
+// the same connection and tenant as the statement
+func loadRows(db *gorm.DB, exprs []clause.Expression) (reflect.Value, error) {
+	rows := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true, Context: db.Statement.Context}).
+		Clauses(clause.Where{Exprs: exprs}).
+		Find(rows.Interface()).Error
+	return rows.Elem(), err
+}
+
+func newAuditLog(db *gorm.DB, action models.AuditAction, row reflect.Value, before, after map[string]interface{}) models.AuditLog {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: db.Statement.Schema.Table,
+		EntityID:   entityID(db, row),
+		Before:     before,
+		After:      after,
+	}
+
+	if actor, ok := utils.ActorFromContext(db.Statement.Context); ok {
+		entry.ActorID = actor.UserID
+		entry.APIKeyID = actor.APIKeyID
+	}
+
+	// Take the tenant from the entity so changes made outside a request,
+	// such as registration, still show up for the right tenant
+	if field := db.Statement.Schema.LookUpField(tenantField); field != nil {
+		if tenantID, zero := field.ValueOf(db.Statement.Context, row); !zero {
+			entry.TenantID = fmt.Sprint(tenantID)
+		}
+	}
+	if entry.TenantID == "" {
+		entry.TenantID, _ = utils.TenantIDFromContext(db.Statement.Context)
+	}
+
+	return entry
+}
+
+func writeAuditLogs(db *gorm.DB, entries []models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true, Context: db.Statement.Context}).
+		Create(&entries).Error
+	if err != nil {
+		db.AddError(fmt.Errorf("failed to write audit log: %w", err))
+	}
+}
+
+func forEachRow(value reflect.Value, fn func(row reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fn(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+func primaryKeys(db *gorm.DB, value reflect.Value) []interface{} {
+	var ids []interface{}
+	forEachRow(value, func(row reflect.Value) {
+		if id, zero := db.Statement.Schema.PrioritizedPrimaryField.ValueOf(db.Statement.Context, row); !zero {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
+
+func entityID(db *gorm.DB, row reflect.Value) string {
+	id, _ := db.Statement.Schema.PrioritizedPrimaryField.ValueOf(db.Statement.Context, row)
+	return fmt.Sprint(id)
+}
+
+// snapshot returns the JSON representation of a row without its associations.
+// Fields hidden from JSON, such as password hashes, are never recorded.
+func snapshot(db *gorm.DB, row reflect.Value) map[string]interface{} {
+	data, err := json.Marshal(row.Interface())
+	if err != nil {
+		return nil
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil
+	}
+
+	for _, relationship := range db.Statement.Schema.Relationships.Relations {
+		name, _, _ := strings.Cut(relationship.Field.Tag.Get("json"), ",")
+		if name == "" {
+			name = relationship.Field.Name
+		}
+		delete(values, name)
+	}
+
+	return values
+}
+
+func diff(before, after map[string]interface{}) map[string]models.AuditChange {
+	changes := make(map[string]models.AuditChange)
+	for key, to := range after {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if from := before[key]; !reflect.DeepEqual(from, to) {
+			changes[key] = models.AuditChange{From: from, To: to}
+		}
+	}
+	for key, from := range before {
+		if _, ok := after[key]; !ok && !auditIgnoredFields[key] {
+			changes[key] = models.AuditChange{From: from, To: nil}
+		}
+	}
+	return changes
+}
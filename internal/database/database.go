@@ -25,17 +25,64 @@ func ConnectDB(cfg *config.Config) (*gorm.DB, error) {
 
 	// Migrate the schema
 	err = db.AutoMigrate(
+		&models.Tenant{},
+		&models.User{},
+		&models.Session{},
+		&models.APIKey{},
 		&models.Reseller{},
 		&models.Product{},
+		&models.StockMovement{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.OrderStatusHistory{},
+		&models.OrderReturn{},
+		&models.OrderReturnItem{},
 		&models.Payment{},
 		&models.Transaction{},
 		&models.Balance{},
+		&models.BalanceAdjustment{},
+		&models.ResellerCreditEntry{},
+		&models.AuditLog{},
+		&models.Sequence{},
+		&models.Shipment{},
+		&models.IdempotencyKey{},
+		&models.PaymentReceipt{},
+		&models.BankStatement{},
+		&models.BankStatementLine{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := migrateTenancy(db); err != nil {
+		return nil, err
+	}
+
+	if err := migrateOrderNumbers(db); err != nil {
+		return nil, err
+	}
+
+	if err := migrateOrderPricing(db); err != nil {
+		return nil, err
+	}
+
+	if err := migratePaymentReceipts(db); err != nil {
+		return nil, err
+	}
+
+	if err := migrateStockLedger(db); err != nil {
+		return nil, err
+	}
+
+	// Scope every query to the tenant of the current request
+	if err := RegisterTenantCallbacks(db); err != nil {
+		return nil, err
+	}
+
+	// Record every change in the audit log
+	if err := RegisterAuditCallbacks(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
\ No newline at end of file
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// orderSequence names the sequence order numbers are drawn from, matching
+// the one used by the order service
+const orderSequence = "order"
+
+// tenantTables lists every table whose rows belong to a tenant
+var tenantTables = []string{
+	"users",
+	"api_keys",
+	"resellers",
+	"products",
+	"orders",
+	"order_items",
+	"payments",
+	"transactions",
+	"balances",
+}
+
+// migrateTenancy moves records created before multi-tenancy into a default
+// tenant and enforces per-tenant uniqueness
+func migrateTenancy(db *gorm.DB) error {
+	if err := assignLegacyTenant(db); err != nil {
+		return err
+	}
+
+	statements := []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_products_tenant_sku ON products (tenant_id, sku) WHERE deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_resellers_tenant_email ON resellers (tenant_id, email) WHERE deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_balances_tenant ON balances (tenant_id) WHERE deleted_at IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_tenant_invoice ON orders (tenant_id, invoice_number) WHERE invoice_number IS NOT NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_bank_statement_lines_tenant_fingerprint ON bank_statement_lines (tenant_id, fingerprint) WHERE fingerprint <> '' AND deleted_at IS NULL",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func assignLegacyTenant(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var pending []string
+		for _, table := range tenantTables {
+			var count int64
+			err := tx.Table(table).Where("tenant_id IS NULL OR tenant_id = ''").Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				pending = append(pending, table)
+			}
+		}
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		tenant := models.Tenant{ID: uuid.NewString(), Name: "Default"}
+		if err := tx.Create(&tenant).Error; err != nil {
+			return err
+		}
+
+		for _, table := range pending {
+			query := fmt.Sprintf("UPDATE %s SET tenant_id = ? WHERE tenant_id IS NULL OR tenant_id = ''", table)
+			if err := tx.Exec(query, tenant.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// migrateOrderNumbers numbers orders created before order numbers existed,
+// per tenant and year in creation order, moves the order sequences past the
+// numbers handed out and enforces per-tenant uniqueness
+func migrateOrderNumbers(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			WITH numbered AS (
+				SELECT o.id, o.tenant_id, EXTRACT(YEAR FROM o.created_at)::int AS year,
+					ROW_NUMBER() OVER (PARTITION BY o.tenant_id, EXTRACT(YEAR FROM o.created_at) ORDER BY o.created_at, o.id) AS position
+				FROM orders o
+				WHERE o.order_number IS NULL OR o.order_number = ''
+			)
+			UPDATE orders SET order_number = 'ORD-' || n.year || '-' || LPAD((n.position + COALESCE(s.value, 0))::text, 6, '0')
+			FROM numbered n
+			LEFT JOIN sequences s ON s.tenant_id = n.tenant_id AND s.name = ? AND s.year = n.year
+			WHERE orders.id = n.id`,
+			orderSequence,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(`
+			INSERT INTO sequences (tenant_id, name, year, value, created_at, updated_at)
+			SELECT tenant_id, ?, SPLIT_PART(order_number, '-', 2)::int, MAX(SPLIT_PART(order_number, '-', 3)::bigint), NOW(), NOW()
+			FROM orders
+			WHERE order_number LIKE 'ORD-%'
+			GROUP BY tenant_id, SPLIT_PART(order_number, '-', 2)
+			ON CONFLICT (tenant_id, name, year) DO UPDATE SET value = GREATEST(sequences.value, EXCLUDED.value)`,
+			orderSequence,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_tenant_number ON orders (tenant_id, order_number)").Error
+	})
+}
+
+// migrateOrderPricing fills the subtotal of orders placed before discounts,
+// tax and shipping fees existed, when the total was the plain item sum
+func migrateOrderPricing(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE orders SET subtotal = total_amount
+		WHERE subtotal = 0 AND total_amount <> 0
+			AND discount_amount = 0 AND tax_amount = 0 AND shipping_fee = 0`,
+	).Error
+}
+
+// migratePaymentReceipts turns the CASH_IN transactions of payments recorded
+// before receipts existed into receipts, so every installment shows up. The
+// payment method of those installments is unknown and left empty.
+func migratePaymentReceipts(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO payment_receipts (tenant_id, payment_id, order_id, amount, method, reference, received_at, recorded_by, notes, transaction_id, created_at, updated_at)
+		SELECT t.tenant_id, p.id, p.order_id, t.amount, '', '', t.date, '', t.description, t.id, t.created_at, NOW()
+		FROM transactions t
+		JOIN payments p ON p.id = t.payment_id
+		WHERE t.type = ?
+			AND t.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM payment_receipts r WHERE r.transaction_id = t.id)`,
+		models.CashIn,
+	).Error
+}
+
+// migrateStockLedger records the stock of products created before stock
+// movements existed as an opening adjustment, so every product's stock
+// equals the sum of its movements
+func migrateStockLedger(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO stock_movements (tenant_id, product_id, quantity, reason, resulting_stock, notes, created_at, updated_at)
+		SELECT p.tenant_id, p.id, p.current_stock, ?, p.current_stock, 'Opening stock', NOW(), NOW()
+		FROM products p
+		WHERE p.deleted_at IS NULL
+			AND p.current_stock <> 0
+			AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id)`,
+		models.StockAdjustment,
+	).Error
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const tenantField = "TenantID"
+
+// RegisterTenantCallbacks scopes every statement on models with a TenantID
+// field to the tenant carried by the statement context. New records get the
+// tenant assigned, and queries, updates and deletes only see rows of that
+// tenant. Statements without a tenant in their context fail with
+// utils.ErrTenantRequired, so a forgotten context never reaches the rows of
+// other tenants. Work that spans tenants, such as login lookups and
+// background jobs, opts in with utils.WithAllTenants.
+func RegisterTenantCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("tenant:assign", assignTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant:query", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:update", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("tenant:delete", scopeTenant); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("tenant:raw", scopeTenant); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("gorm:row").Register("tenant:row", scopeTenant)
+}
+
+// tenantFieldOf returns the TenantID field of the statement's model, or nil
+// for models that do not belong to a tenant, and the tenant of the statement
+// context
+func tenantFieldOf(db *gorm.DB) (*schema.Field, string, bool) {
+	if db.Statement.Schema == nil {
+		return nil, "", false
+	}
+
+	field := db.Statement.Schema.LookUpField(tenantField)
+	if field == nil {
+		return nil, "", false
+	}
+
+	tenantID, ok := utils.TenantIDFromContext(db.Statement.Context)
+	return field, tenantID, ok
+}
+
+func assignTenant(db *gorm.DB) {
+	field, tenantID, ok := tenantFieldOf(db)
+	if field == nil {
+		return
+	}
+	if !ok {
+		// Records created across tenants carry their own tenant
+		if !utils.AllTenantsAllowed(db.Statement.Context) {
+			db.AddError(utils.ErrTenantRequired)
+		}
+		return
+	}
+
+	ctx := db.Statement.Context
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(ctx, reflect.Indirect(rv.Index(i)), tenantID); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if err := field.Set(ctx, rv, tenantID); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+func scopeTenant(db *gorm.DB) {
+	field, tenantID, ok := tenantFieldOf(db)
+	if field == nil {
+		return
+	}
+
+	// Raw SQL is already built and cannot be scoped here, so like statements
+	// without a tenant it only runs when it may span tenants
+	if !ok || db.Statement.SQL.Len() > 0 {
+		if !utils.AllTenantsAllowed(db.Statement.Context) {
+			db.AddError(utils.ErrTenantRequired)
+		}
+		return
+	}
+
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenantID},
+	}})
+}
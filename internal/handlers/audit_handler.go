@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditHandler struct {
+	auditService interfaces.AuditService
+}
+
+func NewAuditHandler(auditService interfaces.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+func (h *AuditHandler) ListAuditLogs(c *fiber.Ctx) error {
+	var filter models.AuditLogFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid query parameters",
+		})
+	}
+
+	logs, err := h.auditService.ListAuditLogs(c.UserContext(), &filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(logs)
+}
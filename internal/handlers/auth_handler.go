@@ -57,3 +57,50 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 
 	return c.JSON(response)
 }
+
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	response, err := h.authService.Refresh(c.Context(), req.RefreshToken, h.tokenSecret, h.tokenExpiration)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	tokenID, ok := c.Locals("tokenID").(string)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Only access tokens can be logged out",
+		})
+	}
+
+	if err := h.authService.Logout(c.Context(), tokenID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "Logged out successfully"})
+}
+
+func (h *AuthHandler) LogoutAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(string)
+
+	if err := h.authService.LogoutAll(c.Context(), userID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{"message": "Logged out from all devices"})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BankStatementHandler handles bank statement reconciliation requests
+type BankStatementHandler struct {
+	Service interfaces.BankStatementService
+}
+
+func NewBankStatementHandler(service interfaces.BankStatementService) *BankStatementHandler {
+	return &BankStatementHandler{Service: service}
+}
+
+// GetParsers lists the supported bank statement layouts
+// @Summary List bank statement layouts
+// @Description List the names of the bank statement layouts that can be imported
+// @Tags Bank Reconciliation
+// @Produce json
+// @Success 200 {array} string
+// @Router /bank-statements/parsers [get]
+func (h *BankStatementHandler) GetParsers(c *fiber.Ctx) error {
+	return c.JSON(h.Service.GetParsers())
+}
+
+// ImportStatement imports a bank statement
+// @Summary Import a bank statement
+// @Description Upload a bank statement CSV. Incoming transfers are stored as statement lines and matched to open orders by order or invoice number in the description, amount left to pay and reseller name. The best match of every line is proposed for confirmation. Transfers already imported with an earlier statement are left out and counted in duplicate_lines.
+// @Tags Bank Reconciliation
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Bank statement CSV"
+// @Param bank formData string false "Statement layout, see /bank-statements/parsers" default(generic)
+// @Success 201 {object} models.BankStatement
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /bank-statements [post]
+func (h *BankStatementHandler) ImportStatement(c *fiber.Ctx) error {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "A statement file is required"})
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Statement file could not be opened"})
+	}
+	defer file.Close()
+
+	bank := c.FormValue("bank", "generic")
+
+	statement, err := h.Service.ImportStatement(c.UserContext(), bank, header.Filename, file)
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(statement)
+}
+
+// GetAllStatements gets all imported bank statements
+// @Summary Get all bank statements
+// @Description Get the imported bank statements, newest first, without their lines
+// @Tags Bank Reconciliation
+// @Produce json
+// @Success 200 {array} models.BankStatement
+// @Failure 500 {object} map[string]string
+// @Router /bank-statements [get]
+func (h *BankStatementHandler) GetAllStatements(c *fiber.Ctx) error {
+	statements, err := h.Service.GetAllStatements(c.UserContext())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(statements)
+}
+
+// GetStatement gets a bank statement
+// @Summary Get a bank statement
+// @Description Get a bank statement with its lines and their proposed or confirmed orders
+// @Tags Bank Reconciliation
+// @Produce json
+// @Param id path string true "Bank statement ID"
+// @Success 200 {object} models.BankStatement
+// @Failure 404 {object} map[string]string
+// @Router /bank-statements/{id} [get]
+func (h *BankStatementHandler) GetStatement(c *fiber.Ctx) error {
+	statement, err := h.Service.GetStatement(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(statement)
+}
+
+// DecideMatches confirms or rejects statement matches in bulk
+// @Summary Confirm or reject bank statement matches
+// @Description Confirm or reject the matches of several statement lines. A confirmed line is recorded as a bank transfer payment of the proposed order, or of the order given with the decision. Decisions are applied line by line and the result of each is returned.
+// @Tags Bank Reconciliation
+// @Accept json
+// @Produce json
+// @Param id path string true "Bank statement ID"
+// @Param decisions body models.BankMatchRequest true "Decisions"
+// @Success 200 {array} models.BankMatchResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /bank-statements/{id}/matches [post]
+func (h *BankStatementHandler) DecideMatches(c *fiber.Ctx) error {
+	req := new(models.BankMatchRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	results, err := h.Service.DecideMatches(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(results)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreditHandler handles reseller credit requests
+type CreditHandler struct {
+	Service interfaces.CreditService
+}
+
+func NewCreditHandler(service interfaces.CreditService) *CreditHandler {
+	return &CreditHandler{Service: service}
+}
+
+// RecordDeposit records a credit deposit
+// @Summary Record a credit deposit
+// @Description Record money a reseller pays in advance. The money is recorded as a CASH_IN DEPOSIT transaction and added to the reseller's credit, which can pay orders later.
+// @Tags Reseller Credit
+// @Accept json
+// @Produce json
+// @Param id path string true "Reseller ID"
+// @Param deposit body models.CreditDepositRequest true "Deposit information"
+// @Success 201 {object} models.ResellerCreditEntry
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /resellers/{id}/credit/deposits [post]
+func (h *CreditHandler) RecordDeposit(c *fiber.Ctx) error {
+	req := new(models.CreditDepositRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	entry, err := h.Service.RecordDeposit(c.UserContext(), c.Params("id"), req)
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(entry)
+}
+
+// GetCreditBalance gets the credit balance of a reseller
+// @Summary Get reseller credit balance
+// @Description Get the credit a reseller can spend on orders
+// @Tags Reseller Credit
+// @Produce json
+// @Param id path string true "Reseller ID"
+// @Success 200 {object} models.CreditBalance
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /resellers/{id}/credit [get]
+func (h *CreditHandler) GetCreditBalance(c *fiber.Ctx) error {
+	balance, err := h.Service.GetCreditBalance(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(balance)
+}
+
+// GetCreditStatement gets the credit statement of a reseller
+// @Summary Get reseller credit statement
+// @Description Get every change to a reseller's credit, oldest first, with the balance after each change
+// @Tags Reseller Credit
+// @Produce json
+// @Param id path string true "Reseller ID"
+// @Success 200 {object} models.CreditStatement
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /resellers/{id}/credit/statement [get]
+func (h *CreditHandler) GetCreditStatement(c *fiber.Ctx) error {
+	statement, err := h.Service.GetCreditStatement(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(statement)
+}
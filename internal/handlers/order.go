@@ -1,11 +1,33 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/aryadhira/reseller-management/internal/interfaces"
 	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
+// OrderStatusRequest represents the request to change an order's status
+// @Description Order status change request information
+type OrderStatusRequest struct {
+	// New status of the order
+	Status models.OrderStatus `json:"status" example:"confirmed"`
+	// Notes about the change
+	Notes string `json:"notes" example:"Confirmed by phone"`
+}
+
+// OrderItemRequest represents the request to add or change an order item
+// @Description Order item request information
+type OrderItemRequest struct {
+	// ID of the product to add, ignored when changing an existing item
+	ProductID string `json:"product_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440002"`
+	// Quantity of the product
+	Quantity int `json:"quantity" example:"3"`
+}
+
 // OrderHandler handles order-related requests
 type OrderHandler struct {
 	Service interfaces.OrderService
@@ -17,7 +39,7 @@ func NewOrderHandler(service interfaces.OrderService) *OrderHandler {
 
 // CreateOrder creates a new order
 // @Summary Create a new order
-// @Description Create a new order for a selected reseller with multiple items
+// @Description Create a new order for a selected reseller with multiple items. Lines and the order can carry a percentage or fixed discount, the configured tax rate is applied to the discounted subtotal and the shipping fee is added on top.
 // @Tags Order Management
 // @Accept json
 // @Produce json
@@ -32,9 +54,9 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	createdOrder, err := h.Service.CreateOrder(order)
+	createdOrder, err := h.Service.CreateOrder(c.UserContext(), order)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(201).JSON(createdOrder)
@@ -42,14 +64,15 @@ func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 
 // GetAllOrders gets all orders
 // @Summary Get all orders
-// @Description Get a list of all orders
+// @Description Get a list of all orders, optionally searched by order or invoice number
 // @Tags Order Management
 // @Produce json
+// @Param search query string false "Part of an order or invoice number"
 // @Success 200 {array} models.Order
 // @Failure 500 {object} map[string]string
 // @Router /orders [get]
 func (h *OrderHandler) GetAllOrders(c *fiber.Ctx) error {
-	orders, err := h.Service.GetAllOrders()
+	orders, err := h.Service.GetAllOrders(c.UserContext(), c.Query("search"))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -62,7 +85,7 @@ func (h *OrderHandler) GetAllOrders(c *fiber.Ctx) error {
 // @Description Get an order by its unique ID
 // @Tags Order Management
 // @Produce json
-// @Param id path string true "Order ID"
+// @Param id path string true "Order ID or order number"
 // @Success 200 {object} models.Order
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -70,7 +93,7 @@ func (h *OrderHandler) GetAllOrders(c *fiber.Ctx) error {
 func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	order, err := h.Service.GetOrderByID(id)
+	order, err := h.Service.GetOrderByID(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
 	}
@@ -84,7 +107,7 @@ func (h *OrderHandler) GetOrderByID(c *fiber.Ctx) error {
 // @Tags Order Management
 // @Accept json
 // @Produce json
-// @Param id path string true "Order ID"
+// @Param id path string true "Order ID or order number"
 // @Param order body models.Order true "Updated order data"
 // @Success 200 {object} models.Order
 // @Failure 400 {object} map[string]string
@@ -98,7 +121,7 @@ func (h *OrderHandler) UpdateOrder(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	updatedOrder, err := h.Service.UpdateOrder(id, order)
+	updatedOrder, err := h.Service.UpdateOrder(c.UserContext(), id, order)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -111,16 +134,16 @@ func (h *OrderHandler) UpdateOrder(c *fiber.Ctx) error {
 // @Description Delete an existing order by ID
 // @Tags Order Management
 // @Produce json
-// @Param id path string true "Order ID"
+// @Param id path string true "Order ID or order number"
 // @Success 204 {object} nil
 // @Failure 500 {object} map[string]string
 // @Router /orders/{id} [delete]
 func (h *OrderHandler) DeleteOrder(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	err := h.Service.DeleteOrder(id)
+	err := h.Service.DeleteOrder(c.UserContext(), id)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.SendStatus(204)
@@ -128,20 +151,341 @@ func (h *OrderHandler) DeleteOrder(c *fiber.Ctx) error {
 
 // CancelOrder cancels an order
 // @Summary Cancel an order
-// @Description Cancel an existing order and restore stock quantities
+// @Description Cancel an existing order and restore stock quantities. Installments paid in cash, by transfer or e-wallet are refunded as CASH_OUT transactions, or added to the reseller's credit with settlement credit. Installments paid from credit always go back to the credit. A refund needs the cash-out permission.
 // @Tags Order Management
 // @Produce json
-// @Param id path string true "Order ID"
+// @Param id path string true "Order ID or order number"
+// @Param settlement query string false "How money paid for the order is given back: refund (default) or credit"
 // @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /orders/{id}/cancel [patch]
 func (h *OrderHandler) CancelOrder(c *fiber.Ctx) error {
 	id := c.Params("id")
+	settlement := models.ReturnSettlement(c.Query("settlement", string(models.SettleRefund)))
 	
-	err := h.Service.CancelOrder(id)
+	err := h.Service.CancelOrder(c.UserContext(), id, settlement)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.JSON(fiber.Map{"message": "Order cancelled successfully"})
-}
\ No newline at end of file
+}
+// AddOrderItem adds an item to an order
+// @Summary Add an item to an order
+// @Description Add a product to a pending or confirmed order. Stock, order total and payment status are updated.
+// @Tags Order Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param item body OrderItemRequest true "Item to add"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/items [post]
+func (h *OrderHandler) AddOrderItem(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := new(OrderItemRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	order, err := h.Service.AddOrderItem(c.UserContext(), id, req.ProductID, req.Quantity)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(order)
+}
+
+// UpdateOrderItem changes the quantity of an order item
+// @Summary Change an order item's quantity
+// @Description Change the quantity of an item in a pending or confirmed order. Stock, order total and payment status are updated.
+// @Tags Order Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param itemID path string true "Order item ID"
+// @Param item body OrderItemRequest true "New quantity"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/items/{itemID} [put]
+func (h *OrderHandler) UpdateOrderItem(c *fiber.Ctx) error {
+	id := c.Params("id")
+	itemID := c.Params("itemID")
+
+	req := new(OrderItemRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	order, err := h.Service.UpdateOrderItem(c.UserContext(), id, itemID, req.Quantity)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(order)
+}
+
+// RemoveOrderItem removes an item from an order
+// @Summary Remove an item from an order
+// @Description Remove an item from a pending or confirmed order and return its stock
+// @Tags Order Management
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param itemID path string true "Order item ID"
+// @Success 200 {object} models.Order
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/items/{itemID} [delete]
+func (h *OrderHandler) RemoveOrderItem(c *fiber.Ctx) error {
+	id := c.Params("id")
+	itemID := c.Params("itemID")
+
+	order, err := h.Service.RemoveOrderItem(c.UserContext(), id, itemID)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(order)
+}
+
+// CreateReturn records goods returned from an order
+// @Summary Return goods from an order
+// @Description Record goods returned from a shipped or completed order. Each item is restocked or written off, and money already paid for the goods is refunded or added to the reseller's credit.
+// @Tags Order Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param return body models.CreateReturnRequest true "Returned items"
+// @Success 201 {object} models.OrderReturn
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/returns [post]
+func (h *OrderHandler) CreateReturn(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := new(models.CreateReturnRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	orderReturn, err := h.Service.CreateReturn(c.UserContext(), id, req)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(orderReturn)
+}
+
+// GetReturns gets the returns of an order
+// @Summary Get an order's returns
+// @Description Get every return recorded against an order, newest first
+// @Tags Order Management
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Success 200 {array} models.OrderReturn
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/returns [get]
+func (h *OrderHandler) GetReturns(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	returns, err := h.Service.GetReturns(c.UserContext(), id)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(returns)
+}
+
+// UpdateOrderStatus changes an order's status
+// @Summary Change an order's status
+// @Description Move an order along its lifecycle: pending, confirmed, shipped, completed. Orders can be cancelled while pending or confirmed, refunding what was paid for them, which needs the cash-out permission.
+// @Tags Order Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param status body OrderStatusRequest true "New status"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/status [patch]
+func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := new(OrderStatusRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	order, err := h.Service.UpdateOrderStatus(c.UserContext(), id, req.Status, req.Notes)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(order)
+}
+
+// GetOrderTimeline gets an order's status history
+// @Summary Get an order's timeline
+// @Description Get every status change of an order, oldest first
+// @Tags Order Management
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Success 200 {array} models.OrderStatusHistory
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/timeline [get]
+func (h *OrderHandler) GetOrderTimeline(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	timeline, err := h.Service.GetOrderTimeline(c.UserContext(), id)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(timeline)
+}
+
+// GetInvoicePDF downloads an order's invoice
+// @Summary Download an order's invoice
+// @Description Render the invoice of a confirmed order as a PDF. The invoice number is assigned on confirmation and never changes.
+// @Tags Order Management
+// @Produce application/pdf
+// @Param id path string true "Order ID or order number"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/invoice.pdf [get]
+func (h *OrderHandler) GetInvoicePDF(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	order, document, err := h.Service.GetInvoicePDF(c.UserContext(), id)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", *order.InvoiceNumber+".pdf"))
+	return c.Send(document)
+}
+
+// CreateShipment records a shipment of an order
+// @Summary Ship an order
+// @Description Record goods of a confirmed or shipped order sent by courier. A shipment with status shipped or delivered moves a confirmed order to shipped, and the shipping cost can be recorded as a CASH_OUT expense.
+// @Tags Order Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param shipment body models.CreateShipmentRequest true "Shipment details"
+// @Success 201 {object} models.Shipment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/shipments [post]
+func (h *OrderHandler) CreateShipment(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := new(models.CreateShipmentRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	shipment, err := h.Service.CreateShipment(c.UserContext(), id, req)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(shipment)
+}
+
+// UpdateShipment updates a shipment of an order
+// @Summary Update a shipment
+// @Description Change a shipment's courier details or move it to shipped or delivered. Delivery completes the order when complete_order is set.
+// @Tags Order Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Param shipmentID path string true "Shipment ID"
+// @Param shipment body models.UpdateShipmentRequest true "Shipment changes"
+// @Success 200 {object} models.Shipment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/shipments/{shipmentID} [put]
+func (h *OrderHandler) UpdateShipment(c *fiber.Ctx) error {
+	id := c.Params("id")
+	shipmentID := c.Params("shipmentID")
+
+	req := new(models.UpdateShipmentRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	shipment, err := h.Service.UpdateShipment(c.UserContext(), id, shipmentID, req)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(shipment)
+}
+
+// GetShipments gets the shipments of an order
+// @Summary Get an order's shipments
+// @Description Get every shipment of an order, oldest first
+// @Tags Order Management
+// @Produce json
+// @Param id path string true "Order ID or order number"
+// @Success 200 {array} models.Shipment
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /orders/{id}/shipments [get]
+func (h *OrderHandler) GetShipments(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	shipments, err := h.Service.GetShipments(c.UserContext(), id)
+	if err != nil {
+		return c.Status(orderErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(shipments)
+}
+
+func orderErrorCode(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrOrderNotFound), errors.Is(err, utils.ErrOrderItemNotFound),
+		errors.Is(err, utils.ErrShipmentNotFound):
+		return 404
+	case errors.Is(err, utils.ErrInvalidOrderStatus), errors.Is(err, utils.ErrInvalidStatusTransition),
+		errors.Is(err, utils.ErrInvalidShipmentStatus), errors.Is(err, utils.ErrInvalidDiscount),
+		errors.Is(err, utils.ErrInvalidSettlement):
+		return 400
+	case errors.Is(err, utils.ErrOrderStatusChanged), errors.Is(err, utils.ErrOrderNotEditable),
+		errors.Is(err, utils.ErrBelowAmountPaid), errors.Is(err, utils.ErrInsufficientStock),
+		errors.Is(err, utils.ErrOrderNotReturnable), errors.Is(err, utils.ErrInsufficientBalance),
+		errors.Is(err, utils.ErrInvoiceNotIssued), errors.Is(err, utils.ErrOrderNotShippable),
+		errors.Is(err, utils.ErrShippingCostRecorded):
+		return 409
+	default:
+		return 500
+	}
+}
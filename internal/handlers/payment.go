@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"time"
+
 	"github.com/aryadhira/reseller-management/internal/interfaces"
 	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -11,10 +15,23 @@ import (
 type PaymentRequest struct {
 	// Amount to be paid
 	Amount float64 `json:"amount" example:"500.00"`
+	// How the money was paid, defaults to cash. Credit pays from the reseller's credit.
+	Method models.PaymentMethod `json:"method,omitempty" example:"bank_transfer"` // cash, bank_transfer, e_wallet, credit
+	// Bank or e-wallet reference number
+	Reference string `json:"reference,omitempty" example:"TRF-20260115-0042"`
+	// Date the money was received, defaults to now and may be in the past
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
 	// Notes about the payment
 	Notes string `json:"notes" example:"Partial payment received"`
 }
 
+// VoidPaymentRequest represents the request to void a payment receipt
+// @Description Void payment request information
+type VoidPaymentRequest struct {
+	// Why the payment is voided
+	Reason string `json:"reason" example:"Amount typed with an extra zero"`
+}
+
 // CashInRequest represents the request to record a cash in transaction
 // @Description Cash in request information
 type CashInRequest struct {
@@ -64,7 +81,7 @@ func NewPaymentHandler(service interfaces.PaymentService) *PaymentHandler {
 // @Failure 500 {object} map[string]string
 // @Router /payments [get]
 func (h *PaymentHandler) GetAllPayments(c *fiber.Ctx) error {
-	payments, err := h.Service.GetAllPayments()
+	payments, err := h.Service.GetAllPayments(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -77,7 +94,7 @@ func (h *PaymentHandler) GetAllPayments(c *fiber.Ctx) error {
 // @Description Get payment information for a specific order
 // @Tags Payment Management
 // @Produce json
-// @Param orderID path string true "Order ID"
+// @Param orderID path string true "Order ID or order number"
 // @Success 200 {object} models.Payment
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -85,7 +102,7 @@ func (h *PaymentHandler) GetAllPayments(c *fiber.Ctx) error {
 func (h *PaymentHandler) GetPaymentByOrderID(c *fiber.Ctx) error {
 	orderID := c.Params("orderID")
 	
-	payment, err := h.Service.GetPaymentByOrderID(orderID)
+	payment, err := h.Service.GetPaymentByOrderID(c.UserContext(), orderID)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Payment not found"})
 	}
@@ -95,14 +112,16 @@ func (h *PaymentHandler) GetPaymentByOrderID(c *fiber.Ctx) error {
 
 // RecordPayment records a payment
 // @Summary Record a payment
-// @Description Record a payment (partial or full) against an order
+// @Description Record a payment (partial or full) against an order. Every payment is kept as a receipt with its method, reference and received date, and the amount paid is the sum of the receipts. Money paid above the remaining amount is added to the reseller's credit, and the credit method pays from that credit.
 // @Tags Payment Management
 // @Accept json
 // @Produce json
-// @Param orderID path string true "Order ID"
+// @Param orderID path string true "Order ID or order number"
 // @Param payment body PaymentRequest true "Payment information"
 // @Success 200 {object} models.Payment
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /payments/order/{orderID}/pay [post]
 func (h *PaymentHandler) RecordPayment(c *fiber.Ctx) error {
@@ -113,14 +132,79 @@ func (h *PaymentHandler) RecordPayment(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	payment, err := h.Service.RecordPayment(orderID, req.Amount, req.Notes)
+	receipt := &models.PaymentReceipt{
+		Amount:    req.Amount,
+		Method:    req.Method,
+		Reference: req.Reference,
+		Notes:     req.Notes,
+	}
+	if req.ReceivedAt != nil {
+		receipt.ReceivedAt = *req.ReceivedAt
+	}
+
+	payment, err := h.Service.RecordPayment(c.UserContext(), orderID, receipt)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.JSON(payment)
 }
 
+// VoidPayment voids a payment receipt
+// @Summary Void a payment
+// @Description Void a payment receipt recorded by mistake. The cash-in of the receipt is reversed with a CASH_OUT REVERSAL transaction, the amount paid and payment status of the order are recalculated and the voided receipt stays in the payment history.
+// @Tags Payment Management
+// @Accept json
+// @Produce json
+// @Param receiptID path string true "Payment receipt ID"
+// @Param void body VoidPaymentRequest true "Void reason"
+// @Success 200 {object} models.Payment
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /payments/receipts/{receiptID}/void [post]
+func (h *PaymentHandler) VoidPayment(c *fiber.Ctx) error {
+	req := new(VoidPaymentRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	payment, err := h.Service.VoidPayment(c.UserContext(), c.Params("receiptID"), req.Reason)
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(payment)
+}
+
+// AllocatePayment allocates one payment across several orders
+// @Summary Allocate a payment across orders
+// @Description Record one incoming payment of a reseller that covers several orders. The amount pays the reseller's unpaid orders oldest due first, or follows the allocations when given. A single CASH_IN transaction is recorded, every paid order gets a receipt linked to it and any leftover is added to the reseller's credit.
+// @Tags Payment Management
+// @Accept json
+// @Produce json
+// @Param allocation body models.PaymentAllocationRequest true "Payment and optional allocations"
+// @Success 200 {object} models.PaymentAllocation
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /payments/allocate [post]
+func (h *PaymentHandler) AllocatePayment(c *fiber.Ctx) error {
+	req := new(models.PaymentAllocationRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	allocation, err := h.Service.AllocatePayment(c.UserContext(), req)
+	if err != nil {
+		return c.Status(paymentErrorCode(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(allocation)
+}
+
 // GetAllTransactions gets all transactions
 // @Summary Get all transactions
 // @Description Get a list of all financial transactions (CASH_IN and CASH_OUT)
@@ -130,7 +214,7 @@ func (h *PaymentHandler) RecordPayment(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]string
 // @Router /transactions [get]
 func (h *PaymentHandler) GetAllTransactions(c *fiber.Ctx) error {
-	transactions, err := h.Service.GetAllTransactions()
+	transactions, err := h.Service.GetAllTransactions(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -155,7 +239,7 @@ func (h *PaymentHandler) RecordCashIn(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	transaction, err := h.Service.RecordCashIn(req.Amount, req.Description, req.ReferenceID)
+	transaction, err := h.Service.RecordCashIn(c.UserContext(), req.Amount, req.Description, req.ReferenceID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -180,7 +264,7 @@ func (h *PaymentHandler) RecordCashOut(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	transaction, err := h.Service.RecordCashOut(req.Category, req.Amount, req.Description)
+	transaction, err := h.Service.RecordCashOut(c.UserContext(), req.Category, req.Amount, req.Description)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -190,12 +274,12 @@ func (h *PaymentHandler) RecordCashOut(c *fiber.Ctx) error {
 
 // UpdateBalance updates the initial balance
 // @Summary Update the initial balance
-// @Description Update the initial balance and adjust accordingly
+// @Description Update the initial balance and adjust accordingly. Every update is kept in the balance history.
 // @Tags Financial Management
 // @Accept json
 // @Produce json
 // @Param balance body BalanceUpdateRequest true "Balance information"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} models.BalanceAdjustment
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /balance [put]
@@ -205,12 +289,31 @@ func (h *PaymentHandler) UpdateBalance(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	err := h.Service.UpdateBalance(req.InitialBalance, req.Notes)
+	userID, _ := c.Locals("userID").(string)
+
+	adjustment, err := h.Service.UpdateBalance(c.UserContext(), userID, req.InitialBalance, req.Notes)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"message": "Balance updated successfully"})
+	return c.JSON(adjustment)
+}
+
+// GetBalanceHistory gets the initial balance adjustments
+// @Summary Get the balance adjustment history
+// @Description Get every change made to the initial balance, newest first
+// @Tags Financial Management
+// @Produce json
+// @Success 200 {array} models.BalanceAdjustment
+// @Failure 500 {object} map[string]string
+// @Router /balance/history [get]
+func (h *PaymentHandler) GetBalanceHistory(c *fiber.Ctx) error {
+	adjustments, err := h.Service.GetBalanceHistory(c.UserContext())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(adjustments)
 }
 
 // GetBalance gets the current balance
@@ -222,7 +325,7 @@ func (h *PaymentHandler) UpdateBalance(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]string
 // @Router /balance [get]
 func (h *PaymentHandler) GetBalance(c *fiber.Ctx) error {
-	balance, err := h.Service.GetBalance()
+	balance, err := h.Service.GetBalance(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -239,10 +342,29 @@ func (h *PaymentHandler) GetBalance(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]string
 // @Router /dashboard [get]
 func (h *PaymentHandler) GetDashboardData(c *fiber.Ctx) error {
-	data, err := h.Service.GetDashboardData()
+	data, err := h.Service.GetDashboardData(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.JSON(data)
-}
\ No newline at end of file
+}
+
+func paymentErrorCode(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrOrderNotFound), errors.Is(err, utils.ErrPaymentNotFound), errors.Is(err, utils.ErrReceiptNotFound),
+		errors.Is(err, utils.ErrResellerNotFound), errors.Is(err, utils.ErrBankStatementNotFound):
+		return 404
+	case errors.Is(err, utils.ErrInvalidPaymentMethod), errors.Is(err, utils.ErrVoidReasonRequired),
+		errors.Is(err, utils.ErrInvalidAmount), errors.Is(err, utils.ErrCreditOverpayment),
+		errors.Is(err, utils.ErrInvalidAllocation), errors.Is(err, utils.ErrUnknownBankParser),
+		errors.Is(err, utils.ErrInvalidBankStatement):
+		return 400
+	case errors.Is(err, utils.ErrReceiptVoided), errors.Is(err, utils.ErrInsufficientCredit),
+		errors.Is(err, utils.ErrOrderFullyPaid), errors.Is(err, utils.ErrOrderCancelled),
+		errors.Is(err, utils.ErrBankStatementImported):
+		return 409
+	default:
+		return 500
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PortalHandler handles requests from reseller portal accounts
+type PortalHandler struct {
+	Service interfaces.PortalService
+}
+
+func NewPortalHandler(service interfaces.PortalService) *PortalHandler {
+	return &PortalHandler{Service: service}
+}
+
+// resellerIDFromToken returns the reseller linked to the authenticated account.
+// Portal endpoints never accept a reseller ID from the client.
+func resellerIDFromToken(c *fiber.Ctx) (string, bool) {
+	resellerID, ok := c.Locals("resellerID").(string)
+	return resellerID, ok && resellerID != ""
+}
+
+// GetProfile gets the reseller profile of the portal account
+// @Summary Get own reseller profile
+// @Description Get the profile of the reseller linked to the authenticated account
+// @Tags Reseller Portal
+// @Produce json
+// @Success 200 {object} models.Reseller
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /portal/me [get]
+func (h *PortalHandler) GetProfile(c *fiber.Ctx) error {
+	resellerID, ok := resellerIDFromToken(c)
+	if !ok {
+		return c.Status(403).JSON(fiber.Map{"error": "Account is not linked to a reseller"})
+	}
+
+	reseller, err := h.Service.GetProfile(c.UserContext(), resellerID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(reseller)
+}
+
+// GetOrders gets the orders of the portal account
+// @Summary Get own orders
+// @Description Get the order history of the reseller linked to the authenticated account
+// @Tags Reseller Portal
+// @Produce json
+// @Success 200 {array} models.Order
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /portal/orders [get]
+func (h *PortalHandler) GetOrders(c *fiber.Ctx) error {
+	resellerID, ok := resellerIDFromToken(c)
+	if !ok {
+		return c.Status(403).JSON(fiber.Map{"error": "Account is not linked to a reseller"})
+	}
+
+	orders, err := h.Service.GetOrders(c.UserContext(), resellerID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(orders)
+}
+
+// GetOrder gets a single order of the portal account
+// @Summary Get own order by ID
+// @Description Get one order of the reseller linked to the authenticated account
+// @Tags Reseller Portal
+// @Produce json
+// @Param id path string true "Order ID"
+// @Success 200 {object} models.Order
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /portal/orders/{id} [get]
+func (h *PortalHandler) GetOrder(c *fiber.Ctx) error {
+	resellerID, ok := resellerIDFromToken(c)
+	if !ok {
+		return c.Status(403).JSON(fiber.Map{"error": "Account is not linked to a reseller"})
+	}
+
+	order, err := h.Service.GetOrder(c.UserContext(), resellerID, c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	return c.JSON(order)
+}
+
+// GetPayments gets the payments of the portal account
+// @Summary Get own payments
+// @Description Get the payments of the reseller linked to the authenticated account
+// @Tags Reseller Portal
+// @Produce json
+// @Success 200 {array} models.Payment
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /portal/payments [get]
+func (h *PortalHandler) GetPayments(c *fiber.Ctx) error {
+	resellerID, ok := resellerIDFromToken(c)
+	if !ok {
+		return c.Status(403).JSON(fiber.Map{"error": "Account is not linked to a reseller"})
+	}
+
+	payments, err := h.Service.GetPayments(c.UserContext(), resellerID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(payments)
+}
+
+// GetBalance gets the outstanding balance of the portal account
+// @Summary Get own outstanding balance
+// @Description Get how much the reseller linked to the authenticated account still owes
+// @Tags Reseller Portal
+// @Produce json
+// @Success 200 {object} interfaces.ResellerBalance
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /portal/balance [get]
+func (h *PortalHandler) GetBalance(c *fiber.Ctx) error {
+	resellerID, ok := resellerIDFromToken(c)
+	if !ok {
+		return c.Status(403).JSON(fiber.Map{"error": "Account is not linked to a reseller"})
+	}
+
+	balance, err := h.Service.GetBalance(c.UserContext(), resellerID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(balance)
+}
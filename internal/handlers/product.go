@@ -11,6 +11,17 @@ import (
 type RestockRequest struct {
 	// Quantity to add to the product's stock
 	Quantity int `json:"quantity" example:"20"`
+	// Notes about the restock
+	Notes string `json:"notes" example:"Supplier delivery"`
+}
+
+// StockAdjustmentRequest represents the request to correct a product's stock
+// @Description Stock adjustment request information
+type StockAdjustmentRequest struct {
+	// Signed quantity to add to or remove from the product's stock
+	Quantity int `json:"quantity" example:"-3"`
+	// Reason for the adjustment
+	Notes string `json:"notes" example:"Damaged during storage"`
 }
 
 // ProductHandler handles product-related requests
@@ -39,7 +50,7 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	createdProduct, err := h.Service.CreateProduct(product)
+	createdProduct, err := h.Service.CreateProduct(c.UserContext(), product)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -56,7 +67,7 @@ func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]string
 // @Router /products [get]
 func (h *ProductHandler) GetAllProducts(c *fiber.Ctx) error {
-	products, err := h.Service.GetAllProducts()
+	products, err := h.Service.GetAllProducts(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -77,7 +88,7 @@ func (h *ProductHandler) GetAllProducts(c *fiber.Ctx) error {
 func (h *ProductHandler) GetProductByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	product, err := h.Service.GetProductByID(id)
+	product, err := h.Service.GetProductByID(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Product not found"})
 	}
@@ -105,7 +116,7 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	updatedProduct, err := h.Service.UpdateProduct(id, product)
+	updatedProduct, err := h.Service.UpdateProduct(c.UserContext(), id, product)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -125,7 +136,7 @@ func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	err := h.Service.DeleteProduct(id)
+	err := h.Service.DeleteProduct(c.UserContext(), id)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -153,7 +164,7 @@ func (h *ProductHandler) RestockProduct(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	updatedProduct, err := h.Service.RestockProduct(id, req.Quantity)
+	updatedProduct, err := h.Service.RestockProduct(c.UserContext(), id, req.Quantity, req.Notes)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -161,6 +172,76 @@ func (h *ProductHandler) RestockProduct(c *fiber.Ctx) error {
 	return c.JSON(updatedProduct)
 }
 
+// AdjustStock corrects a product's stock
+// @Summary Adjust a product's stock
+// @Description Add or remove stock to correct a count, recorded as an adjustment movement
+// @Tags Product Management
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param adjustment body StockAdjustmentRequest true "Adjustment information"
+// @Success 200 {object} models.Product
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/adjust-stock [post]
+func (h *ProductHandler) AdjustStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	req := new(StockAdjustmentRequest)
+	if err := c.BodyParser(req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	updatedProduct, err := h.Service.AdjustStock(c.UserContext(), id, req.Quantity, req.Notes)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(updatedProduct)
+}
+
+// GetStockMovements gets a product's stock movements
+// @Summary Get stock movement history
+// @Description Get every change to a product's stock, newest first
+// @Tags Product Management
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {array} models.StockMovement
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/movements [get]
+func (h *ProductHandler) GetStockMovements(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	movements, err := h.Service.GetStockMovements(c.UserContext(), id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(movements)
+}
+
+// CheckStock checks a product's stock against its movements
+// @Summary Check stock consistency
+// @Description Compare a product's current stock with the sum of its stock movements
+// @Tags Product Management
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} models.StockCheck
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /products/{id}/stock-check [get]
+func (h *ProductHandler) CheckStock(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	check, err := h.Service.CheckStock(c.UserContext(), id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(check)
+}
+
 // GetLowStockProducts gets low stock products
 // @Summary Get products with low stock
 // @Description Get a list of products with stock levels below the minimum alert threshold
@@ -170,7 +251,7 @@ func (h *ProductHandler) RestockProduct(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]string
 // @Router /products/low-stock [get]
 func (h *ProductHandler) GetLowStockProducts(c *fiber.Ctx) error {
-	products, err := h.Service.GetLowStockProducts()
+	products, err := h.Service.GetLowStockProducts(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
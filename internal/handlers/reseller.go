@@ -32,7 +32,7 @@ func (h *ResellerHandler) CreateReseller(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	createdReseller, err := h.Service.CreateReseller(reseller)
+	createdReseller, err := h.Service.CreateReseller(c.UserContext(), reseller)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -49,7 +49,7 @@ func (h *ResellerHandler) CreateReseller(c *fiber.Ctx) error {
 // @Failure 500 {object} map[string]string
 // @Router /resellers [get]
 func (h *ResellerHandler) GetAllResellers(c *fiber.Ctx) error {
-	resellers, err := h.Service.GetAllResellers()
+	resellers, err := h.Service.GetAllResellers(c.UserContext())
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -70,7 +70,7 @@ func (h *ResellerHandler) GetAllResellers(c *fiber.Ctx) error {
 func (h *ResellerHandler) GetResellerByID(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	reseller, err := h.Service.GetResellerByID(id)
+	reseller, err := h.Service.GetResellerByID(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Reseller not found"})
 	}
@@ -91,7 +91,7 @@ func (h *ResellerHandler) GetResellerByID(c *fiber.Ctx) error {
 func (h *ResellerHandler) GetResellerWithOrders(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	reseller, err := h.Service.GetResellerWithOrders(id)
+	reseller, err := h.Service.GetResellerWithOrders(c.UserContext(), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "Reseller not found"})
 	}
@@ -119,7 +119,7 @@ func (h *ResellerHandler) UpdateReseller(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	updatedReseller, err := h.Service.UpdateReseller(id, reseller)
+	updatedReseller, err := h.Service.UpdateReseller(c.UserContext(), id, reseller)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -139,7 +139,7 @@ func (h *ResellerHandler) UpdateReseller(c *fiber.Ctx) error {
 func (h *ResellerHandler) DeleteReseller(c *fiber.Ctx) error {
 	id := c.Params("id")
 	
-	err := h.Service.DeleteReseller(id)
+	err := h.Service.DeleteReseller(c.UserContext(), id)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -16,7 +17,7 @@ func NewUserHandler(userService interfaces.UserService) *UserHandler {
 func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(string)
 
-	user, err := h.userService.GetProfile(c.Context(), userID)
+	user, err := h.userService.GetProfile(c.UserContext(), userID)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": err.Error(),
@@ -25,3 +26,52 @@ func (h *UserHandler) GetProfile(c *fiber.Ctx) error {
 
 	return c.JSON(user)
 }
+
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	users, err := h.userService.ListUsers(c.UserContext())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(users)
+}
+
+func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
+	var req models.CreateUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	user, err := h.userService.CreateUser(c.UserContext(), &req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+func (h *UserHandler) UpdateUserRole(c *fiber.Ctx) error {
+	var req models.UpdateRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	actorID := c.Locals("userID").(string)
+
+	user, err := h.userService.UpdateRole(c.UserContext(), actorID, c.Params("id"), req.Role)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(user)
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+type BankStatementService interface {
+	GetParsers() []string
+	ImportStatement(ctx context.Context, bank string, fileName string, file io.Reader) (*models.BankStatement, error)
+	GetAllStatements(ctx context.Context) ([]models.BankStatement, error)
+	GetStatement(ctx context.Context, id string) (*models.BankStatement, error)
+	DecideMatches(ctx context.Context, statementID string, req *models.BankMatchRequest) ([]models.BankMatchResult, error)
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+type BankStatementRepository interface {
+	Create(ctx context.Context, statement *models.BankStatement) error
+	GetAll(ctx context.Context) ([]models.BankStatement, error)
+	GetByID(ctx context.Context, id string) (*models.BankStatement, error)
+	GetImportedFingerprints(ctx context.Context, fingerprints []string) (map[string]bool, error)
+	GetLineForUpdate(ctx context.Context, statementID string, id string) (*models.BankStatementLine, error)
+	DecideLine(ctx context.Context, line *models.BankStatementLine) error
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+type CreditService interface {
+	RecordDeposit(ctx context.Context, resellerID string, req *models.CreditDepositRequest) (*models.ResellerCreditEntry, error)
+	GetCreditBalance(ctx context.Context, resellerID string) (*models.CreditBalance, error)
+	GetCreditStatement(ctx context.Context, resellerID string) (*models.CreditStatement, error)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+type CreditRepository interface {
+	Create(ctx context.Context, entry *models.ResellerCreditEntry) error
+	GetBalance(ctx context.Context, resellerID string) (float64, error)
+	GetBalanceForUpdate(ctx context.Context, resellerID string) (float64, error)
+	GetEntries(ctx context.Context, resellerID string) ([]models.ResellerCreditEntry, error)
+}
@@ -1,14 +1,27 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type OrderService interface {
-	CreateOrder(order *models.Order) (*models.Order, error)
-	GetAllOrders() ([]models.Order, error)
-	GetOrderByID(id string) (*models.Order, error)
-	UpdateOrder(id string, order *models.Order) (*models.Order, error)
-	DeleteOrder(id string) error
-	CancelOrder(id string) error
+	CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error)
+	GetAllOrders(ctx context.Context, search string) ([]models.Order, error)
+	GetOrderByID(ctx context.Context, id string) (*models.Order, error)
+	UpdateOrder(ctx context.Context, id string, order *models.Order) (*models.Order, error)
+	AddOrderItem(ctx context.Context, orderID string, productID string, quantity int) (*models.Order, error)
+	UpdateOrderItem(ctx context.Context, orderID string, itemID string, quantity int) (*models.Order, error)
+	RemoveOrderItem(ctx context.Context, orderID string, itemID string) (*models.Order, error)
+	CreateReturn(ctx context.Context, orderID string, req *models.CreateReturnRequest) (*models.OrderReturn, error)
+	GetReturns(ctx context.Context, orderID string) ([]models.OrderReturn, error)
+	DeleteOrder(ctx context.Context, id string) error
+	CancelOrder(ctx context.Context, id string, settlement models.ReturnSettlement) error
+	UpdateOrderStatus(ctx context.Context, id string, status models.OrderStatus, notes string) (*models.Order, error)
+	GetOrderTimeline(ctx context.Context, id string) ([]models.OrderStatusHistory, error)
+	GetInvoicePDF(ctx context.Context, id string) (*models.Order, []byte, error)
+	CreateShipment(ctx context.Context, orderID string, req *models.CreateShipmentRequest) (*models.Shipment, error)
+	UpdateShipment(ctx context.Context, orderID string, shipmentID string, req *models.UpdateShipmentRequest) (*models.Shipment, error)
+	GetShipments(ctx context.Context, orderID string) ([]models.Shipment, error)
 }
\ No newline at end of file
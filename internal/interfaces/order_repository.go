@@ -1,14 +1,28 @@
 package interfaces
 
 import (
+	"context"
+	"time"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type OrderRepository interface {
-	Create(order *models.Order) error
-	GetAll() ([]models.Order, error)
-	GetByID(id string) (*models.Order, error)
-	Update(id string, order *models.Order) error
-	Delete(id string) error
-	Cancel(id string) error
+	Create(ctx context.Context, order *models.Order) error
+	GetAll(ctx context.Context, search string) ([]models.Order, error)
+	GetByID(ctx context.Context, id string) (*models.Order, error)
+	GetForUpdate(ctx context.Context, id string) (*models.Order, error)
+	Update(ctx context.Context, id string, order *models.Order) error
+	CreateItem(ctx context.Context, item *models.OrderItem) error
+	UpdateItem(ctx context.Context, item *models.OrderItem) error
+	DeleteItem(ctx context.Context, itemID string) error
+	UpdateTotals(ctx context.Context, order *models.Order, paymentStatus string) error
+	SetInvoiceNumber(ctx context.Context, id string, invoiceNumber string, invoicedAt time.Time) error
+	UpdateItemReturned(ctx context.Context, item *models.OrderItem) error
+	CreateReturn(ctx context.Context, orderReturn *models.OrderReturn) error
+	GetReturns(ctx context.Context, orderID string) ([]models.OrderReturn, error)
+	Delete(ctx context.Context, id string) error
+	Cancel(ctx context.Context, id string) error
+	UpdateStatus(ctx context.Context, id string, from, to models.OrderStatus, notes string) error
+	GetStatusHistory(ctx context.Context, id string) ([]models.OrderStatusHistory, error)
 }
\ No newline at end of file
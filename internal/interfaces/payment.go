@@ -1,6 +1,8 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
@@ -30,13 +32,16 @@ type DashboardData struct {
 }
 
 type PaymentService interface {
-	GetAllPayments() ([]models.Payment, error)
-	GetPaymentByOrderID(orderID string) (*models.Payment, error)
-	RecordPayment(orderID string, amount float64, notes string) (*models.Payment, error)
-	GetAllTransactions() ([]models.Transaction, error)
-	RecordCashIn(amount float64, description string, referenceID *string) (*models.Transaction, error)
-	RecordCashOut(category models.TransactionCategory, amount float64, description string) (*models.Transaction, error)
-	UpdateBalance(initialBalance float64, notes string) error
-	GetBalance() (*models.Balance, error)
-	GetDashboardData() (*DashboardData, error)
+	GetAllPayments(ctx context.Context) ([]models.Payment, error)
+	GetPaymentByOrderID(ctx context.Context, orderID string) (*models.Payment, error)
+	RecordPayment(ctx context.Context, orderID string, receipt *models.PaymentReceipt) (*models.Payment, error)
+	VoidPayment(ctx context.Context, receiptID, reason string) (*models.Payment, error)
+	AllocatePayment(ctx context.Context, req *models.PaymentAllocationRequest) (*models.PaymentAllocation, error)
+	GetAllTransactions(ctx context.Context) ([]models.Transaction, error)
+	RecordCashIn(ctx context.Context, amount float64, description string, referenceID *string) (*models.Transaction, error)
+	RecordCashOut(ctx context.Context, category models.TransactionCategory, amount float64, description string) (*models.Transaction, error)
+	UpdateBalance(ctx context.Context, userID string, initialBalance float64, notes string) (*models.BalanceAdjustment, error)
+	GetBalanceHistory(ctx context.Context) ([]models.BalanceAdjustment, error)
+	GetBalance(ctx context.Context) (*models.Balance, error)
+	GetDashboardData(ctx context.Context) (*DashboardData, error)
 }
\ No newline at end of file
@@ -1,23 +1,34 @@
 package interfaces
 
 import (
+	"context"
 	"time"
 
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type PaymentRepository interface {
-	GetAll() ([]models.Payment, error)
-	GetByOrderID(orderID string) (*models.Payment, error)
-	Create(payment *models.Payment) error
-	Update(payment *models.Payment) error
-	GetAllTransactions() ([]models.Transaction, error)
-	CreateTransaction(transaction *models.Transaction) error
-	UpdateBalance(initialBalance float64) error
-	GetBalance() (*models.Balance, error)
-	GetDashboardData() (*DashboardData, error)
-	GetRecentTransactions(limit int) ([]models.Transaction, error)
-	GetCashInByDateRange(start, end time.Time) (float64, error)
-	GetCashOutByDateRange(start, end time.Time) (float64, error)
-	GetUnpaidOrders() ([]models.Order, error)
+	GetAll(ctx context.Context) ([]models.Payment, error)
+	GetByOrderID(ctx context.Context, orderID string) (*models.Payment, error)
+	GetByOrderIDs(ctx context.Context, orderIDs []string) ([]models.Payment, error)
+	Create(ctx context.Context, payment *models.Payment) error
+	Update(ctx context.Context, payment *models.Payment) error
+	CancelByOrderID(ctx context.Context, orderID string) error
+	CreateReceipt(ctx context.Context, receipt *models.PaymentReceipt) error
+	GetReceiptForUpdate(ctx context.Context, id string) (*models.PaymentReceipt, error)
+	VoidReceipt(ctx context.Context, receipt *models.PaymentReceipt) error
+	RefreshAmountPaid(ctx context.Context, orderID string) (float64, error)
+	GetAllTransactions(ctx context.Context) ([]models.Transaction, error)
+	CreateTransaction(ctx context.Context, transaction *models.Transaction) error
+	UpdateBalance(ctx context.Context, initialBalance float64) error
+	AdjustInitialBalance(ctx context.Context, adjustment *models.BalanceAdjustment) error
+	GetBalanceAdjustments(ctx context.Context) ([]models.BalanceAdjustment, error)
+	GetBalance(ctx context.Context) (*models.Balance, error)
+	GetDashboardData(ctx context.Context) (*DashboardData, error)
+	GetRecentTransactions(ctx context.Context, limit int) ([]models.Transaction, error)
+	GetCashInByDateRange(ctx context.Context, start, end time.Time) (float64, error)
+	GetCashOutByDateRange(ctx context.Context, start, end time.Time) (float64, error)
+	GetUnpaidOrders(ctx context.Context) ([]models.Order, error)
+	GetUnpaidOrdersByReseller(ctx context.Context, resellerID string) ([]models.Order, error)
+	MarkOverdue(ctx context.Context, now time.Time) (int64, error)
 }
\ No newline at end of file
@@ -0,0 +1,32 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+// ResellerBalance represents the outstanding balance of a reseller
+// @Description Reseller balance information
+type ResellerBalance struct {
+	// ID of the reseller
+	ResellerID string `json:"reseller_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Total amount of all non-cancelled orders
+	TotalOrdered float64 `json:"total_ordered" example:"5000.00"`
+	// Total amount paid so far
+	TotalPaid float64 `json:"total_paid" example:"3500.00"`
+	// Amount still owed
+	Outstanding float64 `json:"outstanding" example:"1500.00"`
+	// Number of orders that are not fully paid
+	OpenOrders int `json:"open_orders" example:"2"`
+}
+
+// PortalService exposes read-only data to reseller portal accounts. Every
+// method is scoped to the given reseller, which must come from the token.
+type PortalService interface {
+	GetProfile(ctx context.Context, resellerID string) (*models.Reseller, error)
+	GetOrders(ctx context.Context, resellerID string) ([]models.Order, error)
+	GetOrder(ctx context.Context, resellerID string, orderID string) (*models.Order, error)
+	GetPayments(ctx context.Context, resellerID string) ([]models.Payment, error)
+	GetBalance(ctx context.Context, resellerID string) (*ResellerBalance, error)
+}
@@ -1,15 +1,20 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type ProductService interface {
-	CreateProduct(product *models.Product) (*models.Product, error)
-	GetAllProducts() ([]models.Product, error)
-	GetProductByID(id string) (*models.Product, error)
-	UpdateProduct(id string, product *models.Product) (*models.Product, error)
-	DeleteProduct(id string) error
-	RestockProduct(id string, quantity int) (*models.Product, error)
-	GetLowStockProducts() ([]models.Product, error)
+	CreateProduct(ctx context.Context, product *models.Product) (*models.Product, error)
+	GetAllProducts(ctx context.Context) ([]models.Product, error)
+	GetProductByID(ctx context.Context, id string) (*models.Product, error)
+	UpdateProduct(ctx context.Context, id string, product *models.Product) (*models.Product, error)
+	DeleteProduct(ctx context.Context, id string) error
+	RestockProduct(ctx context.Context, id string, quantity int, notes string) (*models.Product, error)
+	AdjustStock(ctx context.Context, id string, quantity int, notes string) (*models.Product, error)
+	GetStockMovements(ctx context.Context, id string) ([]models.StockMovement, error)
+	CheckStock(ctx context.Context, id string) (*models.StockCheck, error)
+	GetLowStockProducts(ctx context.Context) ([]models.Product, error)
 }
\ No newline at end of file
@@ -1,15 +1,19 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type ProductRepository interface {
-	Create(product *models.Product) error
-	GetAll() ([]models.Product, error)
-	GetByID(id string) (*models.Product, error)
-	Update(id string, product *models.Product) error
-	Delete(id string) error
-	Restock(id string, quantity int) error
-	GetLowStock() ([]models.Product, error)
+	Create(ctx context.Context, product *models.Product) error
+	GetAll(ctx context.Context) ([]models.Product, error)
+	GetByID(ctx context.Context, id string) (*models.Product, error)
+	Update(ctx context.Context, id string, product *models.Product) error
+	Delete(ctx context.Context, id string) error
+	MoveStock(ctx context.Context, movement *models.StockMovement) error
+	GetMovements(ctx context.Context, productID string) ([]models.StockMovement, error)
+	GetMovementTotal(ctx context.Context, productID string) (int, error)
+	GetLowStock(ctx context.Context) ([]models.Product, error)
 }
\ No newline at end of file
@@ -2,12 +2,46 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
+	CreateOwner(ctx context.Context, tenant *models.Tenant, owner *models.User) error
 	FindByID(ctx context.Context, id string) (*models.User, error)
 	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindAll(ctx context.Context) ([]models.User, error)
+	UpdateRole(ctx context.Context, id string, role models.Role) error
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error)
+	FindByAccessTokenID(ctx context.Context, accessTokenID string) (*models.Session, error)
+	Rotate(ctx context.Context, oldID string, next *models.Session) error
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForUser(ctx context.Context, userID string) error
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	FindAll(ctx context.Context) ([]models.APIKey, error)
+	FindByID(ctx context.Context, id string) (*models.APIKey, error)
+	FindByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	TouchLastUsed(ctx context.Context, id string, usedAt time.Time) error
+}
+
+type AuditLogRepository interface {
+	FindAll(ctx context.Context, filter *models.AuditLogFilter) ([]models.AuditLog, error)
+}
+
+type IdempotencyKeyRepository interface {
+	Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error)
+	FindByKey(ctx context.Context, key string) (*models.IdempotencyKey, error)
+	SaveResponse(ctx context.Context, id string, statusCode int, contentType string, response []byte) error
+	Delete(ctx context.Context, id string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
 }
@@ -1,14 +1,16 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type ResellerService interface {
-	CreateReseller(reseller *models.Reseller) (*models.Reseller, error)
-	GetAllResellers() ([]models.Reseller, error)
-	GetResellerByID(id string) (*models.Reseller, error)
-	UpdateReseller(id string, reseller *models.Reseller) (*models.Reseller, error)
-	DeleteReseller(id string) error
-	GetResellerWithOrders(id string) (*models.Reseller, error)
+	CreateReseller(ctx context.Context, reseller *models.Reseller) (*models.Reseller, error)
+	GetAllResellers(ctx context.Context) ([]models.Reseller, error)
+	GetResellerByID(ctx context.Context, id string) (*models.Reseller, error)
+	UpdateReseller(ctx context.Context, id string, reseller *models.Reseller) (*models.Reseller, error)
+	DeleteReseller(ctx context.Context, id string) error
+	GetResellerWithOrders(ctx context.Context, id string) (*models.Reseller, error)
 }
\ No newline at end of file
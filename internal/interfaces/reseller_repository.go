@@ -1,14 +1,16 @@
 package interfaces
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 )
 
 type ResellerRepository interface {
-	Create(reseller *models.Reseller) error
-	GetAll() ([]models.Reseller, error)
-	GetByID(id string) (*models.Reseller, error)
-	Update(id string, reseller *models.Reseller) error
-	Delete(id string) error
-	GetWithOrders(id string) (*models.Reseller, error)
+	Create(ctx context.Context, reseller *models.Reseller) error
+	GetAll(ctx context.Context) ([]models.Reseller, error)
+	GetByID(ctx context.Context, id string) (*models.Reseller, error)
+	Update(ctx context.Context, id string, reseller *models.Reseller) error
+	Delete(ctx context.Context, id string) error
+	GetWithOrders(ctx context.Context, id string) (*models.Reseller, error)
 }
\ No newline at end of file
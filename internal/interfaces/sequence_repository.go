@@ -0,0 +1,7 @@
+package interfaces
+
+import "context"
+
+type SequenceRepository interface {
+	Next(ctx context.Context, name string, year int) (int64, error)
+}
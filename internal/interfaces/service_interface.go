@@ -10,8 +10,24 @@ import (
 type AuthService interface {
 	Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error)
 	Login(ctx context.Context, req *models.LoginRequest, jwtSecret string, expiresIn time.Duration) (*models.AuthResponse, error)
+	Refresh(ctx context.Context, refreshToken string, jwtSecret string, expiresIn time.Duration) (*models.AuthResponse, error)
+	Logout(ctx context.Context, accessTokenID string) error
+	LogoutAll(ctx context.Context, userID string) error
 }
 
 type UserService interface {
 	GetProfile(ctx context.Context, userID string) (*models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error)
+	UpdateRole(ctx context.Context, actorID string, userID string, role models.Role) (*models.User, error)
+}
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID string, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+type AuditService interface {
+	ListAuditLogs(ctx context.Context, filter *models.AuditLogFilter) ([]models.AuditLog, error)
 }
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+type ShipmentRepository interface {
+	Create(ctx context.Context, shipment *models.Shipment) error
+	Update(ctx context.Context, shipment *models.Shipment) error
+	GetForUpdate(ctx context.Context, orderID string, id string) (*models.Shipment, error)
+	GetByOrderID(ctx context.Context, orderID string) ([]models.Shipment, error)
+}
@@ -0,0 +1,141 @@
+// Package invoice renders order invoices as PDF documents
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/pdf"
+	"github.com/aryadhira/reseller-management/internal/utils"
+)
+
+const (
+	marginLeft   = 50.0
+	marginRight  = pdf.PageWidth - 50.0
+	marginTop    = pdf.PageHeight - 60.0
+	marginBottom = 70.0
+	lineHeight   = 14.0
+)
+
+// Render returns the invoice of an order as a PDF. The order must have an
+// invoice number and be loaded with its reseller, items, products and
+// payment. Rendering the same order twice gives the same document.
+func Render(order *models.Order) ([]byte, error) {
+	if order.InvoiceNumber == nil {
+		return nil, fmt.Errorf("order %s has no invoice number", order.ID)
+	}
+
+	w := &writer{doc: pdf.New(), y: marginTop}
+
+	// Header
+	w.doc.Text(marginLeft, w.y, pdf.HelveticaBold, 20, "INVOICE")
+	w.y -= 28
+	w.field("Invoice number", *order.InvoiceNumber)
+	if order.InvoicedAt != nil {
+		w.field("Invoice date", order.InvoicedAt.Format("02 January 2006"))
+	}
+	w.field("Order", order.OrderNumber)
+	w.field("Order date", order.OrderDate.Format("02 January 2006"))
+	w.y -= lineHeight
+
+	// Reseller details
+	w.doc.Text(marginLeft, w.y, pdf.HelveticaBold, 11, "Bill to")
+	w.y -= lineHeight
+	for _, line := range []string{order.Reseller.Name, order.Reseller.Email, order.Reseller.Phone, order.Reseller.Address} {
+		if line != "" {
+			w.doc.Text(marginLeft, w.y, pdf.Helvetica, 10, line)
+			w.y -= lineHeight
+		}
+	}
+	w.y -= lineHeight
+
+	// Line items, the amount column is after the line discount
+	w.row("Product", "Qty", "Price", "Discount", "Amount")
+	w.rule()
+	for _, item := range order.OrderItems {
+		name := item.Product.Name
+		if name == "" {
+			name = item.ProductID
+		}
+		discount := ""
+		if item.DiscountAmount > 0 {
+			discount = utils.FormatCurrency(-item.DiscountAmount)
+		}
+		w.row(name, fmt.Sprint(item.Quantity), utils.FormatCurrency(item.Price), discount,
+			utils.FormatCurrency(item.Subtotal-item.DiscountAmount))
+		if item.ReturnedQuantity > 0 {
+			amount := float64(item.ReturnedQuantity) * item.UnitPrice()
+			w.row("  Returned", fmt.Sprint(-item.ReturnedQuantity), utils.FormatCurrency(item.UnitPrice()), "",
+				utils.FormatCurrency(-amount))
+		}
+	}
+	w.rule()
+
+	// Totals
+	amountPaid := 0.0
+	if order.Payment != nil {
+		amountPaid = order.Payment.AmountPaid
+	}
+	w.total("Subtotal", order.Subtotal)
+	if order.DiscountAmount > 0 {
+		w.total(discountLabel(order), -order.DiscountAmount)
+	}
+	if order.TaxRate > 0 {
+		w.total(fmt.Sprintf("Tax (%s%%)", strconv.FormatFloat(order.TaxRate, 'f', -1, 64)), order.TaxAmount)
+	}
+	if order.ShippingFee > 0 {
+		w.total("Shipping", order.ShippingFee)
+	}
+	w.total("Total", order.TotalAmount)
+	w.total("Amount paid", amountPaid)
+	w.total("Remaining balance", order.TotalAmount-amountPaid)
+
+	return w.doc.Bytes(), nil
+}
+
+// writer keeps track of the current position while laying out an invoice
+type writer struct {
+	doc *pdf.Document
+	y   float64
+}
+
+// next moves to the next line, starting a new page when the page is full
+func (w *writer) next() {
+	w.y -= lineHeight
+	if w.y < marginBottom {
+		w.doc.AddPage()
+		w.y = marginTop
+	}
+}
+
+func (w *writer) field(label, value string) {
+	w.doc.Text(marginLeft, w.y, pdf.Helvetica, 10, label+":")
+	w.doc.Text(marginLeft+110, w.y, pdf.Helvetica, 10, value)
+	w.y -= lineHeight
+}
+
+// row writes a table row in a fixed-width font so columns line up
+func (w *writer) row(product, quantity, price, discount, amount string) {
+	if len(product) > 30 {
+		product = product[:27] + "..."
+	}
+	w.doc.Text(marginLeft, w.y, pdf.Courier, 9, fmt.Sprintf("%-30s %5s %14s %14s %14s", product, quantity, price, discount, amount))
+	w.next()
+}
+
+func (w *writer) rule() {
+	w.doc.Line(marginLeft, w.y+lineHeight-4, marginRight, w.y+lineHeight-4)
+}
+
+func (w *writer) total(label string, amount float64) {
+	w.doc.Text(marginLeft, w.y, pdf.Courier, 9, fmt.Sprintf("%65s %14s", label, utils.FormatCurrency(amount)))
+	w.next()
+}
+
+func discountLabel(order *models.Order) string {
+	if order.DiscountType == models.DiscountPercentage {
+		return fmt.Sprintf("Discount (%s%%)", strconv.FormatFloat(order.DiscountValue, 'f', -1, 64))
+	}
+	return "Discount"
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/utils"
+)
+
+// IdempotencyKeyPurger periodically removes idempotency keys of every tenant
+// past their retention window. Expired keys are already ignored by the
+// middleware, this only keeps the table small.
+type IdempotencyKeyPurger struct {
+	keys     interfaces.IdempotencyKeyRepository
+	interval time.Duration
+}
+
+func NewIdempotencyKeyPurger(keys interfaces.IdempotencyKeyRepository, interval time.Duration) *IdempotencyKeyPurger {
+	return &IdempotencyKeyPurger{keys: keys, interval: interval}
+}
+
+// Run purges once right away and then on every interval until ctx is done
+func (p *IdempotencyKeyPurger) Run(ctx context.Context) {
+	runEvery(ctx, "Idempotency key purge", p.interval, func(ctx context.Context) error {
+		_, err := p.keys.DeleteExpired(utils.WithAllTenants(ctx), time.Now())
+		return err
+	})
+}
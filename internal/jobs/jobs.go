@@ -0,0 +1,27 @@
+// Package jobs holds work that runs in the background next to the API
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runEvery calls fn right away and then on every interval until ctx is done.
+// Failures are logged and retried on the next tick.
+func runEvery(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("%s failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
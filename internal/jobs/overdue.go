@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
+)
+
+// OverdueChecker periodically flags unpaid and partially paid orders past
+// their due date as overdue. It covers the orders of every tenant.
+type OverdueChecker struct {
+	payments repository.PaymentRepository
+	interval time.Duration
+}
+
+func NewOverdueChecker(payments repository.PaymentRepository, interval time.Duration) *OverdueChecker {
+	return &OverdueChecker{payments: payments, interval: interval}
+}
+
+// Run checks once right away and then on every interval until ctx is done
+func (c *OverdueChecker) Run(ctx context.Context) {
+	runEvery(ctx, "Overdue check", c.interval, func(ctx context.Context) error {
+		_, err := c.Check(ctx)
+		return err
+	})
+}
+
+// Check flags the orders that are overdue now and returns how many there were
+func (c *OverdueChecker) Check(ctx context.Context) (int64, error) {
+	marked, err := c.payments.MarkOverdue(utils.WithAllTenants(ctx), time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if marked > 0 {
+		log.Printf("Marked %d orders as overdue", marked)
+	}
+	return marked, nil
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthMiddleware validates bearer tokens and role permissions on protected routes
+type AuthMiddleware struct {
+	tokenSecret string
+	userRepo    interfaces.UserRepository
+	sessionRepo interfaces.SessionRepository
+	apiKeyRepo  interfaces.APIKeyRepository
+}
+
+func NewAuthMiddleware(tokenSecret string, userRepo interfaces.UserRepository, sessionRepo interfaces.SessionRepository, apiKeyRepo interfaces.APIKeyRepository) *AuthMiddleware {
+	return &AuthMiddleware{
+		tokenSecret: tokenSecret,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		apiKeyRepo:  apiKeyRepo,
+	}
+}
+
+// Protected rejects requests without a valid, unrevoked access token or API
+// key and stores the authenticated user ID and role in c.Locals("userID") and
+// c.Locals("role"). Access tokens also set c.Locals("tokenID"), API keys set
+// c.Locals("apiKeyID") and c.Locals("scopes"), and reseller portal accounts
+// get c.Locals("resellerID"). The request context (c.UserContext) is scoped
+// to the user's tenant.
+func (m *AuthMiddleware) Protected() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if apiKey, ok := apiKeyCredential(c); ok {
+			return m.authenticateAPIKey(c, apiKey)
+		}
+
+		tokenString, ok := bearerToken(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Missing or malformed authorization header",
+			})
+		}
+
+		claims, err := utils.ValidateToken(tokenString, m.tokenSecret)
+		if err != nil || claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or expired token",
+			})
+		}
+
+		// Reject tokens whose session was logged out or rotated
+		session, err := m.sessionRepo.FindByAccessTokenID(c.Context(), claims.ID)
+		if err != nil || session.RevokedAt != nil || session.UserID != claims.UserID {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Token has been revoked",
+			})
+		}
+
+		// Load the user so role changes take effect immediately. The tenant is
+		// only known once the user is found.
+		user, err := m.userRepo.FindByID(utils.WithAllTenants(c.Context()), claims.UserID)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "User no longer exists",
+			})
+		}
+
+		setUserLocals(c, user)
+		c.Locals("tokenID", claims.ID)
+
+		return c.Next()
+	}
+}
+
+// authenticateAPIKey accepts requests made with an active API key. The key
+// acts on behalf of the user who issued it, limited to the key's scopes.
+func (m *AuthMiddleware) authenticateAPIKey(c *fiber.Ctx, plainKey string) error {
+	key, err := m.apiKeyRepo.FindByHash(utils.WithAllTenants(c.Context()), utils.HashToken(plainKey))
+	if err != nil || !key.IsActive(time.Now()) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or revoked API key",
+		})
+	}
+
+	ctx := utils.WithTenantID(c.Context(), key.TenantID)
+	issuer, err := m.userRepo.FindByID(ctx, key.CreatedBy)
+	if err != nil || issuer.TenantID != key.TenantID {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or revoked API key",
+		})
+	}
+
+	if err := m.apiKeyRepo.TouchLastUsed(ctx, key.ID, time.Now()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	setUserLocals(c, issuer)
+	c.SetUserContext(utils.WithActor(c.UserContext(), utils.Actor{UserID: issuer.ID, APIKeyID: key.ID}))
+	c.Locals("apiKeyID", key.ID)
+	c.Locals("scopes", key.Scopes)
+
+	return c.Next()
+}
+
+// RequirePermission rejects requests whose role lacks any of the given
+// permissions. Requests made with an API key also need the matching scope.
+// It must run after Protected.
+func (m *AuthMiddleware) RequirePermission(permissions ...models.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(models.Role)
+		scopes, isAPIKey := c.Locals("scopes").([]models.Permission)
+
+		for _, permission := range permissions {
+			if !role.HasPermission(permission) || (isAPIKey && !hasScope(scopes, permission)) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "You do not have permission to perform this action",
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// RequirePermissionWhen is RequirePermission for the requests match selects,
+// other requests pass through. It guards what a route only does on request,
+// such as paying out money.
+func (m *AuthMiddleware) RequirePermissionWhen(match func(c *fiber.Ctx) bool, permissions ...models.Permission) fiber.Handler {
+	require := m.RequirePermission(permissions...)
+	return func(c *fiber.Ctx) error {
+		if !match(c) {
+			return c.Next()
+		}
+		return require(c)
+	}
+}
+
+// setUserLocals stores the authenticated user in c.Locals and scopes the
+// request context (c.UserContext) to the user's tenant and audit actor
+func setUserLocals(c *fiber.Ctx, user *models.User) {
+	ctx := utils.WithTenantID(c.UserContext(), user.TenantID)
+	c.SetUserContext(utils.WithActor(ctx, utils.Actor{UserID: user.ID}))
+	c.Locals("userID", user.ID)
+	c.Locals("tenantID", user.TenantID)
+	c.Locals("email", user.Email)
+	c.Locals("role", user.Role)
+	if user.ResellerID != nil {
+		c.Locals("resellerID", *user.ResellerID)
+	}
+}
+
+func hasScope(scopes []models.Permission, permission models.Permission) bool {
+	for _, scope := range scopes {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyCredential returns an API key sent either in the X-API-Key header or
+// as a bearer token
+func apiKeyCredential(c *fiber.Ctx) (string, bool) {
+	if key := strings.TrimSpace(c.Get("X-API-Key")); key != "" {
+		return key, true
+	}
+
+	token, ok := bearerToken(c)
+	if ok && strings.HasPrefix(token, utils.APIKeyPrefix) {
+		return token, true
+	}
+	return "", false
+}
+
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	header := c.Get(fiber.HeaderAuthorization)
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for a mutating request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyMiddleware makes retried mutating requests safe. The first
+// request with an Idempotency-Key runs normally and its response is stored;
+// repeats of the same request within the retention window get the stored
+// response back without running again.
+type IdempotencyMiddleware struct {
+	repo interfaces.IdempotencyKeyRepository
+	ttl  time.Duration
+}
+
+func NewIdempotencyMiddleware(repo interfaces.IdempotencyKeyRepository, ttl time.Duration) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+// Handle must run after Protected, so keys are kept per tenant and tied to
+// the caller. Requests without the header, and reads, pass straight through.
+func (m *IdempotencyMiddleware) Handle() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(c.Method()) {
+			return c.Next()
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+		}
+
+		ctx := c.UserContext()
+		now := time.Now()
+		record := &models.IdempotencyKey{
+			ID:          uuid.NewString(),
+			Key:         key,
+			Fingerprint: requestFingerprint(c),
+			ExpiresAt:   now.Add(m.ttl),
+		}
+
+		reserved, err := m.repo.Reserve(ctx, record)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if !reserved {
+			existing, err := m.repo.FindByKey(ctx, key)
+			if errors.Is(err, utils.ErrIdempotencyKeyNotFound) {
+				// The request holding the key released it in the meantime
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "A request with this Idempotency-Key is still being processed",
+				})
+			}
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			}
+
+			// A key past its retention window starts over
+			if existing.IsExpired(now) {
+				if err := m.repo.Delete(ctx, existing.ID); err != nil {
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+				}
+				reserved, err = m.repo.Reserve(ctx, record)
+				if err != nil {
+					return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+				}
+			}
+
+			if !reserved {
+				return m.replay(c, existing, record.Fingerprint)
+			}
+		}
+
+		if err := c.Next(); err != nil {
+			// Let the client retry requests that failed outright
+			m.release(ctx, record)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			m.release(ctx, record)
+			return nil
+		}
+
+		response := append([]byte(nil), c.Response().Body()...)
+		contentType := string(c.Response().Header.ContentType())
+		if err := m.repo.SaveResponse(ctx, record.ID, status, contentType, response); err != nil {
+			log.Printf("Failed to store response for idempotency key %q: %v", record.Key, err)
+			m.release(ctx, record)
+		}
+		return nil
+	}
+}
+
+// release removes the reservation of a request that was not stored, so the
+// client can retry it. A key that cannot be removed blocks retries until it
+// expires, so the failure is logged.
+func (m *IdempotencyMiddleware) release(ctx context.Context, record *models.IdempotencyKey) {
+	if err := m.repo.Delete(ctx, record.ID); err != nil {
+		log.Printf("Failed to release idempotency key %q: %v", record.Key, err)
+	}
+}
+
+// replay answers a repeated request with the stored response of the first one
+func (m *IdempotencyMiddleware) replay(c *fiber.Ctx, existing *models.IdempotencyKey, fingerprint string) error {
+	if existing.Fingerprint != fingerprint {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Idempotency-Key was already used for a different request",
+		})
+	}
+
+	if !existing.IsCompleted() {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A request with this Idempotency-Key is still being processed",
+		})
+	}
+
+	c.Set(IdempotentReplayedHeader, "true")
+	if existing.ContentType != "" {
+		c.Set(fiber.HeaderContentType, existing.ContentType)
+	}
+	return c.Status(existing.StatusCode).Send(existing.Response)
+}
+
+// requestFingerprint identifies a request by its caller, method, URL and body
+func requestFingerprint(c *fiber.Ctx) string {
+	hash := sha256.New()
+	if actor, ok := utils.ActorFromContext(c.UserContext()); ok {
+		hash.Write([]byte(actor.UserID + "|" + actor.APIKeyID + "\n"))
+	}
+	hash.Write([]byte(c.Method() + " " + c.OriginalURL() + "\n"))
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// APIKey represents a key used by scripts and integrations to call the API
+type APIKey struct {
+	ID         string       `json:"id" gorm:"primarykey"`
+	TenantID   string       `json:"-" gorm:"size:36;index"`
+	Name       string       `json:"name" gorm:"size:100;not null"`
+	Prefix     string       `json:"prefix" gorm:"size:16;not null"` // Leading characters of the key, for identification
+	KeyHash    string       `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Scopes     []Permission `json:"scopes" gorm:"serializer:json;not null"`
+	CreatedBy  string       `json:"created_by" gorm:"index;not null"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	ExpiresAt  *time.Time   `json:"expires_at"`
+	RevokedAt  *time.Time   `json:"revoked_at"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// IsActive reports whether the key can still be used
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// API Key Requests & Responses
+type CreateAPIKeyRequest struct {
+	Name      string       `json:"name" validate:"required"`
+	Scopes    []Permission `json:"scopes" validate:"required"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"` // Plain key, only returned once
+}
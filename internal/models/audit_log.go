@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// AuditAction names the kind of change recorded in an audit log entry
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "create"
+	AuditActionUpdate AuditAction = "update"
+	AuditActionDelete AuditAction = "delete"
+)
+
+// AuditLog represents a single change made to a stored entity. Entries are
+// written automatically and can only be read through the API.
+type AuditLog struct {
+	ID         string                 `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID   string                 `json:"-" gorm:"size:36;index"`
+	ActorID    string                 `json:"actor_id" gorm:"size:36;index"`       // User who made the change, empty for system changes
+	APIKeyID   string                 `json:"api_key_id,omitempty" gorm:"size:36"` // Set when the change was made with an API key
+	Action     AuditAction            `json:"action" gorm:"size:20;not null"`
+	EntityType string                 `json:"entity_type" gorm:"size:50;not null;index:idx_audit_logs_entity"`
+	EntityID   string                 `json:"entity_id" gorm:"size:36;index:idx_audit_logs_entity"`
+	Before     map[string]interface{} `json:"before" gorm:"type:jsonb;serializer:json"` // Entity state before the change, empty on create
+	After      map[string]interface{} `json:"after" gorm:"type:jsonb;serializer:json"`  // Entity state after the change, empty on delete
+	Changes    map[string]AuditChange `json:"changes" gorm:"type:jsonb;serializer:json"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"index"`
+}
+
+// AuditChange holds the old and new value of a single changed field
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditLogFilter narrows the audit log listing
+type AuditLogFilter struct {
+	EntityType string `query:"entity"`
+	EntityID   string `query:"entity_id"`
+	ActorID    string `query:"user_id"`
+	Limit      int    `query:"limit"`
+	Offset     int    `query:"offset"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// BalanceAdjustment represents a single correction of the initial balance
+// @Description Initial balance adjustment information
+type BalanceAdjustment struct {
+	BaseModel
+	// Initial balance before the adjustment
+	OldValue float64 `json:"old_value" example:"10000.00"`
+	// Initial balance after the adjustment
+	NewValue float64 `json:"new_value" gorm:"not null" example:"12000.00"`
+	// Difference between the new and old value
+	Delta float64 `json:"delta" example:"2000.00"`
+	// Reason for the adjustment
+	Notes string `json:"notes" example:"Corrected opening cash count"`
+	// User who made the adjustment
+	UserID string `json:"user_id" gorm:"size:36;index" example:"550e8400-e29b-41d4-a716-446655440000"`
+}
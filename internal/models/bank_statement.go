@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// BankLineStatus defines where a bank statement line is in reconciliation
+type BankLineStatus string
+
+const (
+	BankLineUnmatched BankLineStatus = "unmatched" // No open order looked like a match
+	BankLineProposed  BankLineStatus = "proposed"  // An order was proposed and awaits a decision
+	BankLineConfirmed BankLineStatus = "confirmed" // Recorded as a payment of the order
+	BankLineRejected  BankLineStatus = "rejected"  // Not a payment of any order
+)
+
+// IsDecided reports whether a user already confirmed or rejected the line
+func (s BankLineStatus) IsDecided() bool {
+	return s == BankLineConfirmed || s == BankLineRejected
+}
+
+// BankStatement represents an uploaded bank statement
+// @Description Bank statement information
+type BankStatement struct {
+	BaseModel
+	// Name of the parser used to read the file
+	Bank string `json:"bank" gorm:"size:50;not null" example:"bca"`
+	// Name of the uploaded file
+	FileName string `json:"file_name" example:"mutasi-januari.csv"`
+	// User who uploaded the statement
+	ImportedBy string `json:"imported_by" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Incoming transfers left out because an earlier statement already had them
+	DuplicateLines int `json:"duplicate_lines" gorm:"not null;default:0" example:"0"`
+	// Incoming transfers read from the statement
+	Lines []BankStatementLine `json:"lines,omitempty" gorm:"foreignKey:StatementID"`
+}
+
+// BankStatementLine represents an incoming transfer on a bank statement and
+// the order it is matched to
+// @Description Bank statement line information
+type BankStatementLine struct {
+	BaseModel
+	// ID of the statement the line was read from
+	StatementID string `json:"statement_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440006"`
+	// Date the bank booked the transfer
+	Date time.Time `json:"date"`
+	// Description the bank shows for the transfer
+	Description string `json:"description" example:"TRSF E-BANKING CR ORD-2026-000042 TOKO MAJU"`
+	// Bank reference of the transfer
+	Reference string `json:"reference" gorm:"size:100" example:"FTSCY/WS95031"`
+	// Amount received
+	Amount float64 `json:"amount" gorm:"not null" example:"500.00"`
+	// Identifies the transfer across imports, see bankstatement.Fingerprint
+	Fingerprint string `json:"-" gorm:"size:64;not null;default:''"`
+	// Reconciliation status of the line
+	Status BankLineStatus `json:"status" gorm:"size:20;not null;index" example:"proposed"` // unmatched, proposed, confirmed, rejected
+	// ID of the proposed or confirmed order
+	OrderID *string `json:"order_id" gorm:"type:uuid" example:"550e8400-e29b-41d4-a716-446655440001"`
+	// How strongly the line matches the order
+	MatchScore int `json:"match_score" example:"80"`
+	// What matched, such as order number and amount
+	MatchReason string `json:"match_reason" example:"order number, amount"`
+	// ID of the payment receipt recorded when the match was confirmed
+	ReceiptID *string `json:"receipt_id" example:"550e8400-e29b-41d4-a716-446655440007"`
+	// User who confirmed or rejected the line
+	DecidedBy string `json:"decided_by,omitempty" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Date the line was confirmed or rejected
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+// BankMatchDecision confirms or rejects the match of one statement line
+// @Description Bank match decision information
+type BankMatchDecision struct {
+	// ID of the statement line
+	LineID string `json:"line_id" example:"550e8400-e29b-41d4-a716-446655440008"`
+	// confirm records the line as a payment, reject leaves it unrecorded
+	Action string `json:"action" example:"confirm"` // confirm, reject
+	// ID or order number of the order to pay instead of the proposed one
+	OrderID string `json:"order_id,omitempty" example:"ORD-2026-000042"`
+}
+
+// BankMatchRequest holds the decisions for several statement lines
+// @Description Bank match request information
+type BankMatchRequest struct {
+	// Decisions, applied one line at a time
+	Decisions []BankMatchDecision `json:"decisions"`
+}
+
+// BankMatchResult is the outcome of one decision
+// @Description Bank match result information
+type BankMatchResult struct {
+	// ID of the statement line
+	LineID string `json:"line_id" example:"550e8400-e29b-41d4-a716-446655440008"`
+	// Status of the line after the decision
+	Status BankLineStatus `json:"status" example:"confirmed"`
+	// ID of the payment receipt recorded for a confirmed line
+	ReceiptID *string `json:"receipt_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440007"`
+	// Why the decision could not be applied
+	Error string `json:"error,omitempty" example:"order is already fully paid"`
+}
@@ -11,6 +11,8 @@ import (
 type BaseModel struct {
 	// Unique identifier for the entity
 	ID string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Tenant (business) that owns the entity, set automatically from the request
+	TenantID string `gorm:"size:36;index" json:"-"`
 	// Creation timestamp
 	CreatedAt time.Time `json:"created_at"`
 	// Last update timestamp
@@ -0,0 +1,35 @@
+package models
+
+import "math"
+
+// DiscountType defines how a discount value is applied
+type DiscountType string
+
+const (
+	DiscountPercentage DiscountType = "percentage" // Value is a percentage of the amount
+	DiscountFixed      DiscountType = "fixed"      // Value is an amount of money
+)
+
+// IsValid reports whether the type is a known discount type. No type means
+// no discount.
+func (t DiscountType) IsValid() bool {
+	return t == "" || t == DiscountPercentage || t == DiscountFixed
+}
+
+// Amount returns the discount on base for the given value. A discount never
+// exceeds base.
+func (t DiscountType) Amount(base, value float64) float64 {
+	var amount float64
+	switch t {
+	case DiscountPercentage:
+		amount = base * value / 100
+	case DiscountFixed:
+		amount = value
+	}
+	return roundMoney(math.Max(0, math.Min(amount, base)))
+}
+
+// roundMoney rounds an amount to whole cents
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
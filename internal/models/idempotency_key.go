@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the outcome of a mutating request made with an
+// Idempotency-Key header, so a retry of the same request gets the original
+// response instead of repeating the change
+type IdempotencyKey struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID    string    `json:"-" gorm:"size:36;uniqueIndex:idx_idempotency_keys_scope"`
+	Key         string    `json:"key" gorm:"size:255;not null;uniqueIndex:idx_idempotency_keys_scope"`
+	Fingerprint string    `json:"-" gorm:"size:64;not null"`             // SHA-256 of the caller, method, path and body
+	StatusCode  int       `json:"status_code" gorm:"not null;default:0"` // 0 while the first request is still running
+	ContentType string    `json:"content_type" gorm:"size:100"`
+	Response    []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index;not null"`
+}
+
+// IsCompleted reports whether the original request has finished
+func (k *IdempotencyKey) IsCompleted() bool {
+	return k.StatusCode != 0
+}
+
+// IsExpired reports whether the key is past its retention window
+func (k *IdempotencyKey) IsExpired(now time.Time) bool {
+	return !now.Before(k.ExpiresAt)
+}
@@ -2,26 +2,115 @@ package models
 
 import "time"
 
+// OrderStatus defines the lifecycle stage of an order
+type OrderStatus string
+
+const (
+	OrderPending   OrderStatus = "pending"
+	OrderConfirmed OrderStatus = "confirmed"
+	OrderShipped   OrderStatus = "shipped"
+	OrderCompleted OrderStatus = "completed"
+	OrderCancelled OrderStatus = "cancelled"
+)
+
+// orderTransitions lists the statuses each status may move to
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPending:   {OrderConfirmed, OrderCancelled},
+	OrderConfirmed: {OrderShipped, OrderCancelled},
+	OrderShipped:   {OrderCompleted},
+	OrderCompleted: {},
+	OrderCancelled: {},
+}
+
+// IsValid reports whether the status is one of the known order statuses
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order may move from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// CalculateTotals derives the subtotal, order discount, tax and grand total
+// from the order's items
+func (o *Order) CalculateTotals() {
+	subtotal := 0.0
+	for i := range o.OrderItems {
+		subtotal += o.OrderItems[i].NetSubtotal()
+	}
+
+	o.Subtotal = roundMoney(subtotal)
+	o.DiscountAmount = o.DiscountType.Amount(o.Subtotal, o.DiscountValue)
+	o.TaxAmount = roundMoney((o.Subtotal - o.DiscountAmount) * o.TaxRate / 100)
+	o.TotalAmount = roundMoney(o.Subtotal - o.DiscountAmount + o.TaxAmount + o.ShippingFee)
+}
+
+// IsPastDue reports whether the order's due date has passed at now
+func (o *Order) IsPastDue(now time.Time) bool {
+	return o.DueDate != nil && now.After(*o.DueDate)
+}
+
+// DaysPastDue returns the number of whole days the order is past its due date
+func (o *Order) DaysPastDue(now time.Time) int {
+	if !o.IsPastDue(now) {
+		return 0
+	}
+	return int(now.Sub(*o.DueDate).Hours() / 24)
+}
+
 // Order represents an order in the system
 // @Description Order information
 type Order struct {
 	BaseModel
+	// Human-readable order number, unique per tenant
+	OrderNumber string `json:"order_number" gorm:"size:30" example:"ORD-2026-000123"`
 	// ID of the reseller who placed the order
 	ResellerID string `json:"reseller_id" gorm:"not null" example:"550e8400-e29b-41d4-a716-446655440000"`
 	// Items in the order (simplified to avoid recursion)
 	OrderItems []OrderItem `json:"order_items,omitempty" gorm:"foreignKey:OrderID"`
-	// Total amount of the order
+	// Sum of the discounted item subtotals, without returned goods
+	Subtotal float64 `json:"subtotal" gorm:"not null;default:0" example:"1999.98"`
+	// How the order discount is applied
+	DiscountType DiscountType `json:"discount_type" gorm:"size:20" example:"fixed"` // percentage, fixed
+	// Percentage or amount of the order discount
+	DiscountValue float64 `json:"discount_value" gorm:"not null;default:0" example:"100"`
+	// Discount on the order subtotal
+	DiscountAmount float64 `json:"discount_amount" gorm:"not null;default:0" example:"100.00"`
+	// Tax rate in percent, taken from the configuration when the order is placed
+	TaxRate float64 `json:"tax_rate" gorm:"not null;default:0" example:"11"`
+	// Tax on the discounted subtotal
+	TaxAmount float64 `json:"tax_amount" gorm:"not null;default:0" example:"208.99"`
+	// Shipping fee charged to the reseller
+	ShippingFee float64 `json:"shipping_fee" gorm:"not null;default:0" example:"25.00"`
+	// Grand total of the order: subtotal - discount + tax + shipping fee
 	TotalAmount float64 `json:"total_amount" gorm:"not null" example:"1999.98"`
 	// Status of the order
-	Status string `json:"status" gorm:"default:'pending'" example:"pending"` // pending, confirmed, cancelled, completed
+	Status OrderStatus `json:"status" gorm:"default:'pending'" example:"pending"` // pending, confirmed, shipped, completed, cancelled
 	// Payment status of the order
 	PaymentStatus string `json:"payment_status" gorm:"default:'unpaid'" example:"unpaid"` // unpaid, partially_paid, paid, overdue
 	// Date when the order was placed
 	OrderDate time.Time `json:"order_date" gorm:"default:CURRENT_TIMESTAMP"`
+	// Date by which the order must be paid, from the reseller's payment terms
+	DueDate *time.Time `json:"due_date" gorm:"index"`
+	// Days the payment is past its due date, filled in for unpaid order listings
+	DaysOverdue int `json:"days_overdue,omitempty" gorm:"-"`
 	// Additional notes about the order
 	Notes string `json:"notes" example:"Special delivery instructions"`
+	// Invoice number, assigned when the order is confirmed
+	InvoiceNumber *string `json:"invoice_number" gorm:"size:30" example:"INV-2026-000001"`
+	// Date when the invoice number was assigned
+	InvoicedAt *time.Time `json:"invoiced_at"`
 	// Reseller who placed the order (simplified to avoid recursion)
 	Reseller Reseller `json:"reseller,omitempty" gorm:"foreignKey:ResellerID"`
 	// Payment information for the order (simplified to avoid recursion)
 	Payment *Payment `json:"payment,omitempty" gorm:"foreignKey:OrderID"`
+	// Shipments sending the order to the reseller
+	Shipments []Shipment `json:"shipments,omitempty" gorm:"foreignKey:OrderID"`
 }
\ No newline at end of file
@@ -14,8 +14,35 @@ type OrderItem struct {
 	Price float64 `json:"price" gorm:"not null" example:"999.99"` // Price at the time of order
 	// Subtotal for this item (quantity * price)
 	Subtotal float64 `json:"subtotal" gorm:"not null" example:"1999.98"`
+	// How the line discount is applied
+	DiscountType DiscountType `json:"discount_type" gorm:"size:20" example:"percentage"` // percentage, fixed
+	// Percentage or amount of the line discount
+	DiscountValue float64 `json:"discount_value" gorm:"not null;default:0" example:"10"`
+	// Discount on the whole line
+	DiscountAmount float64 `json:"discount_amount" gorm:"not null;default:0" example:"199.99"`
+	// Quantity returned by the reseller after delivery
+	ReturnedQuantity int `json:"returned_quantity" gorm:"not null;default:0" example:"0"`
 	// Order this item belongs to (simplified to avoid recursion)
 	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
 	// Product being ordered (simplified to avoid recursion)
 	Product Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-}
\ No newline at end of file
+}
+
+// Recalculate sets the subtotal and discount from the quantity and price
+func (i *OrderItem) Recalculate() {
+	i.Subtotal = roundMoney(float64(i.Quantity) * i.Price)
+	i.DiscountAmount = i.DiscountType.Amount(i.Subtotal, i.DiscountValue)
+}
+
+// UnitPrice returns the price of a single unit after the line discount
+func (i *OrderItem) UnitPrice() float64 {
+	if i.Quantity == 0 {
+		return 0
+	}
+	return (i.Subtotal - i.DiscountAmount) / float64(i.Quantity)
+}
+
+// NetSubtotal returns the discounted subtotal of the quantity the reseller kept
+func (i *OrderItem) NetSubtotal() float64 {
+	return roundMoney(float64(i.Quantity-i.ReturnedQuantity) * i.UnitPrice())
+}
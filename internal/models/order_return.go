@@ -0,0 +1,72 @@
+package models
+
+// ReturnDisposition defines what happens to returned goods
+type ReturnDisposition string
+
+const (
+	ReturnRestock  ReturnDisposition = "restock"   // Goods go back into stock
+	ReturnWriteOff ReturnDisposition = "write_off" // Goods are damaged and discarded
+)
+
+// ReturnSettlement defines how money owed to the reseller for a return is settled
+type ReturnSettlement string
+
+const (
+	SettleRefund ReturnSettlement = "refund" // Paid back in cash as a CASH_OUT transaction
+	SettleCredit ReturnSettlement = "credit" // Added to the reseller's credit
+)
+
+// OrderReturn represents goods a reseller sent back from an order
+// @Description Order return information
+type OrderReturn struct {
+	BaseModel
+	// ID of the order the goods came from
+	OrderID string `json:"order_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440001"`
+	// ID of the reseller who returned the goods
+	ResellerID string `json:"reseller_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Reason for the return
+	Reason string `json:"reason" example:"Damaged in transit"`
+	// Value of the returned goods at their order price
+	Amount float64 `json:"amount" example:"200.00"`
+	// How money already paid for the returned goods is settled
+	Settlement ReturnSettlement `json:"settlement" gorm:"size:20;not null" example:"refund"` // refund, credit
+	// Amount refunded or credited, the part of the returned value already paid
+	SettledAmount float64 `json:"settled_amount" example:"150.00"`
+	// ID of the refund transaction, for refunds
+	TransactionID *string `json:"transaction_id" example:"550e8400-e29b-41d4-a716-446655440003"`
+	// User who recorded the return
+	UserID string `json:"user_id" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Returned items
+	Items []OrderReturnItem `json:"items" gorm:"foreignKey:ReturnID"`
+}
+
+// OrderReturnItem represents the returned quantity of a single order item
+// @Description Order return item information
+type OrderReturnItem struct {
+	BaseModel
+	// ID of the return this item belongs to
+	ReturnID string `json:"return_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440004"`
+	// ID of the returned order item
+	OrderItemID string `json:"order_item_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440005"`
+	// ID of the returned product
+	ProductID string `json:"product_id" gorm:"type:uuid;not null" example:"550e8400-e29b-41d4-a716-446655440002"`
+	// Quantity returned
+	Quantity int `json:"quantity" gorm:"not null" example:"2"`
+	// Whether the goods are restocked or written off
+	Disposition ReturnDisposition `json:"disposition" gorm:"size:20;not null" example:"restock"` // restock, write_off
+	// Value of the returned quantity at the order price
+	Amount float64 `json:"amount" example:"200.00"`
+}
+
+// CreateReturnRequest lists the goods coming back from an order
+type CreateReturnRequest struct {
+	Reason     string              `json:"reason"`
+	Settlement ReturnSettlement    `json:"settlement"`
+	Items      []ReturnItemRequest `json:"items"`
+}
+
+type ReturnItemRequest struct {
+	OrderItemID string            `json:"order_item_id"`
+	Quantity    int               `json:"quantity"`
+	Disposition ReturnDisposition `json:"disposition"`
+}
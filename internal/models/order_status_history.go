@@ -0,0 +1,17 @@
+package models
+
+// OrderStatusHistory represents a single status change in an order's timeline
+// @Description Order status change information
+type OrderStatusHistory struct {
+	BaseModel
+	// ID of the order whose status changed
+	OrderID string `json:"order_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440001"`
+	// Status before the change, empty when the order was created
+	FromStatus OrderStatus `json:"from_status" gorm:"size:20" example:"pending"`
+	// Status after the change
+	ToStatus OrderStatus `json:"to_status" gorm:"size:20;not null" example:"confirmed"`
+	// User who changed the status, empty for system changes
+	UserID string `json:"user_id" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Notes about the change
+	Notes string `json:"notes" example:"Confirmed by phone"`
+}
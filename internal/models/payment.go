@@ -10,7 +10,7 @@ type Payment struct {
 	OrderID string `json:"order_id" gorm:"not null;unique" example:"550e8400-e29b-41d4-a716-446655440001"`
 	// Total amount of the order
 	TotalAmount float64 `json:"total_amount" gorm:"not null" example:"1999.98"`
-	// Amount that has been paid
+	// Amount that has been paid: the receipts, less what returns gave back
 	AmountPaid float64 `json:"amount_paid" gorm:"default:0" example:"500.00"`
 	// Status of the payment
 	Status string `json:"status" gorm:"default:'unpaid'" example:"partially_paid"` // unpaid, partially_paid, paid, overdue
@@ -18,6 +18,8 @@ type Payment struct {
 	PaymentDate time.Time `json:"payment_date" gorm:"default:CURRENT_TIMESTAMP"`
 	// Additional notes about the payment
 	Notes string `json:"notes" example:"Partial payment received"`
+	// Installments received for this payment, oldest first
+	Receipts []PaymentReceipt `json:"receipts,omitempty" gorm:"foreignKey:PaymentID"`
 	// Cash in transactions associated with this payment (simplified to avoid recursion)
 	CashInTransactions []Transaction `json:"cash_in_transactions,omitempty" gorm:"foreignKey:PaymentID"`
 	// Order this payment is for (simplified to avoid recursion)
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// OrderAllocation is the part of an incoming payment assigned to one order
+// @Description Order allocation information
+type OrderAllocation struct {
+	// ID or order number of the order to pay
+	OrderID string `json:"order_id" example:"ORD-2026-000042"`
+	// Amount assigned to the order
+	Amount float64 `json:"amount" example:"250.00"`
+}
+
+// PaymentAllocationRequest represents one incoming payment of a reseller that
+// covers several orders
+// @Description Payment allocation request information
+type PaymentAllocationRequest struct {
+	// ID of the reseller who paid
+	ResellerID string `json:"reseller_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Total amount received
+	Amount float64 `json:"amount" example:"1000.00"`
+	// How the money was paid, defaults to cash
+	Method PaymentMethod `json:"method,omitempty" example:"bank_transfer"` // cash, bank_transfer, e_wallet
+	// Bank or e-wallet reference number
+	Reference string `json:"reference,omitempty" example:"TRF-20260115-0042"`
+	// Date the money was received, defaults to now and may be in the past
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+	// Notes about the payment
+	Notes string `json:"notes" example:"Transfer for January invoices"`
+	// Explicit split of the amount, when empty the amount pays the unpaid orders of the reseller oldest due first
+	Allocations []OrderAllocation `json:"allocations,omitempty"`
+}
+
+// PaymentAllocation is the outcome of allocating one incoming payment
+// @Description Payment allocation information
+type PaymentAllocation struct {
+	// ID of the reseller who paid
+	ResellerID string `json:"reseller_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// ID of the single CASH_IN transaction recorded for the payment
+	TransactionID string `json:"transaction_id" example:"550e8400-e29b-41d4-a716-446655440003"`
+	// Total amount received
+	Amount float64 `json:"amount" example:"1000.00"`
+	// Amount that went to orders
+	Allocated float64 `json:"allocated" example:"900.00"`
+	// Leftover added to the reseller's credit
+	Credited float64 `json:"credited" example:"100.00"`
+	// Receipts created for the paid orders
+	Receipts []PaymentReceipt `json:"receipts"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// PaymentMethod defines how a reseller paid
+type PaymentMethod string
+
+const (
+	PaymentCash         PaymentMethod = "cash"
+	PaymentBankTransfer PaymentMethod = "bank_transfer"
+	PaymentEWallet      PaymentMethod = "e_wallet"
+	PaymentCredit       PaymentMethod = "credit" // Paid from the reseller's credit, no money changes hands
+)
+
+// IsValid reports whether the method is one of the known payment methods
+func (m PaymentMethod) IsValid() bool {
+	return m == PaymentCash || m == PaymentBankTransfer || m == PaymentEWallet || m == PaymentCredit
+}
+
+// PaymentReceipt represents a single installment received for an order
+// @Description Payment receipt information
+type PaymentReceipt struct {
+	BaseModel
+	// ID of the payment this installment belongs to
+	PaymentID string `json:"payment_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440002"`
+	// ID of the paid order
+	OrderID string `json:"order_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440001"`
+	// Amount received
+	Amount float64 `json:"amount" gorm:"not null" example:"500.00"`
+	// How the money was paid, empty for installments recorded before methods were tracked
+	Method PaymentMethod `json:"method" gorm:"size:20" example:"bank_transfer"` // cash, bank_transfer, e_wallet, credit
+	// Bank or e-wallet reference number
+	Reference string `json:"reference" gorm:"size:100" example:"TRF-20260115-0042"`
+	// Date the money was received, may be earlier than the date it was recorded
+	ReceivedAt time.Time `json:"received_at" gorm:"not null"`
+	// User who recorded the installment
+	RecordedBy string `json:"recorded_by" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Additional notes about the installment
+	Notes string `json:"notes" example:"Second installment"`
+	// ID of the CASH_IN transaction created for the installment, empty when paid from credit
+	TransactionID *string `json:"transaction_id" example:"550e8400-e29b-41d4-a716-446655440003"`
+	// Date the installment was voided, voided installments do not count towards the amount paid
+	VoidedAt *time.Time `json:"voided_at,omitempty"`
+	// User who voided the installment
+	VoidedBy string `json:"voided_by,omitempty" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Why the installment was voided
+	VoidReason string `json:"void_reason,omitempty" example:"Amount typed with an extra zero"`
+	// ID of the CASH_OUT transaction that reversed the installment
+	ReversalTransactionID *string `json:"reversal_transaction_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440004"`
+}
+
+// IsVoided reports whether the installment was voided
+func (r *PaymentReceipt) IsVoided() bool {
+	return r.VoidedAt != nil
+}
@@ -8,8 +8,8 @@ type Product struct {
 	Name string `json:"name" gorm:"not null" example:"Laptop"`
 	// Product description
 	Description string `json:"description" example:"High performance laptop"`
-	// Product SKU (Stock Keeping Unit)
-	SKU string `json:"sku" gorm:"unique;not null" example:"LAP-001"`
+	// Product SKU (Stock Keeping Unit), unique per tenant
+	SKU string `json:"sku" gorm:"not null" example:"LAP-001"`
 	// Product price
 	Price float64 `json:"price" gorm:"not null" example:"999.99"`
 	// Current stock quantity
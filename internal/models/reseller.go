@@ -6,14 +6,16 @@ type Reseller struct {
 	BaseModel
 	// Reseller name
 	Name string `json:"name" gorm:"not null" example:"John Doe"`
-	// Reseller email
-	Email string `json:"email" gorm:"unique;not null" example:"john@example.com"`
+	// Reseller email, unique per tenant
+	Email string `json:"email" gorm:"not null" example:"john@example.com"`
 	// Reseller phone number
 	Phone string `json:"phone" example:"+1-234-567-8900"`
 	// Reseller address
 	Address string `json:"address" example:"123 Main St, City, Country"`
 	// Status of the reseller
 	Status string `json:"status" gorm:"default:'active'" example:"active"` // active, inactive
+	// Days the reseller has to pay an order, 0 when orders have no due date
+	PaymentTermDays int `json:"payment_term_days" gorm:"not null;default:0" example:"14"`
 	// Orders associated with this reseller (simplified to avoid recursion)
 	Orders []Order `json:"orders,omitempty" gorm:"foreignKey:ResellerID"`
 }
\ No newline at end of file
@@ -0,0 +1,65 @@
+package models
+
+// CreditEntryType defines why a reseller's credit changed
+type CreditEntryType string
+
+const (
+	CreditReturn       CreditEntryType = "return"       // Value of returned goods that was already paid
+	CreditDeposit      CreditEntryType = "deposit"      // Money paid in advance by the reseller
+	CreditOverpayment  CreditEntryType = "overpayment"  // Money received above the remaining amount of an order
+	CreditPayment      CreditEntryType = "payment"      // Credit spent on paying an order
+	CreditReversal     CreditEntryType = "reversal"     // Credit given back when a payment made from credit is voided
+	CreditCancellation CreditEntryType = "cancellation" // Credit given back when an order paid from credit is cancelled
+)
+
+// ResellerCreditEntry represents a single change to a reseller's credit.
+// The credit balance is the sum of all entries of the reseller.
+// @Description Reseller credit entry information
+type ResellerCreditEntry struct {
+	BaseModel
+	// ID of the reseller whose credit changed
+	ResellerID string `json:"reseller_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Signed amount, positive when credit was added
+	Amount float64 `json:"amount" gorm:"not null" example:"150.00"`
+	// Reason for the change
+	Type CreditEntryType `json:"type" gorm:"size:20;not null" example:"return"`
+	// ID of the record that caused the change, such as a return
+	ReferenceID *string `json:"reference_id" gorm:"index" example:"550e8400-e29b-41d4-a716-446655440004"`
+	// User who made the change, empty for system changes
+	UserID string `json:"user_id" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Notes about the change
+	Notes string `json:"notes" example:"Return of damaged goods"`
+	// Credit balance after the change, only filled in statements
+	Balance float64 `json:"balance,omitempty" gorm:"-" example:"350.00"`
+}
+
+// CreditBalance represents the credit a reseller can spend on orders
+// @Description Reseller credit balance information
+type CreditBalance struct {
+	// ID of the reseller
+	ResellerID string `json:"reseller_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Current credit balance
+	Balance float64 `json:"balance" example:"350.00"`
+}
+
+// CreditStatement lists the credit entries of a reseller, oldest first, with
+// the running balance after each entry
+// @Description Reseller credit statement information
+type CreditStatement struct {
+	CreditBalance
+	// Credit entries, oldest first
+	Entries []ResellerCreditEntry `json:"entries"`
+}
+
+// CreditDepositRequest represents a deposit paid in advance by a reseller
+// @Description Credit deposit request information
+type CreditDepositRequest struct {
+	// Amount deposited
+	Amount float64 `json:"amount" example:"1000.00"`
+	// How the money was paid, defaults to cash
+	Method PaymentMethod `json:"method,omitempty" example:"bank_transfer"` // cash, bank_transfer, e_wallet
+	// Bank or e-wallet reference number
+	Reference string `json:"reference,omitempty" example:"TRF-20260115-0042"`
+	// Notes about the deposit
+	Notes string `json:"notes" example:"Advance for next month's orders"`
+}
@@ -0,0 +1,120 @@
+package models
+
+// Role defines the set of permissions granted to a user
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleCashier   Role = "cashier"
+	RoleWarehouse Role = "warehouse"
+	RoleReseller  Role = "reseller" // Self-service portal account linked to a reseller
+)
+
+// Permission names a single action that can be granted to a role
+type Permission string
+
+const (
+	PermResellersRead    Permission = "resellers:read"
+	PermResellersWrite   Permission = "resellers:write"
+	PermResellersDelete  Permission = "resellers:delete"
+	PermProductsRead     Permission = "products:read"
+	PermProductsWrite    Permission = "products:write"
+	PermOrdersRead       Permission = "orders:read"
+	PermOrdersWrite      Permission = "orders:write"
+	PermPaymentsRead     Permission = "payments:read"
+	PermPaymentsWrite    Permission = "payments:write"
+	PermTransactionsRead Permission = "transactions:read"
+	PermCashIn           Permission = "transactions:cash-in"
+	PermCashOut          Permission = "transactions:cash-out"
+	PermBalanceRead      Permission = "balance:read"
+	PermBalanceWrite     Permission = "balance:write"
+	PermDashboardRead    Permission = "dashboard:read"
+	PermUsersManage      Permission = "users:manage"
+	PermPortalRead       Permission = "portal:read"
+	PermAPIKeysManage    Permission = "api-keys:manage"
+	PermAuditRead        Permission = "audit:read"
+)
+
+// AllPermissions lists every permission known to the system
+var AllPermissions = []Permission{
+	PermResellersRead,
+	PermResellersWrite,
+	PermResellersDelete,
+	PermProductsRead,
+	PermProductsWrite,
+	PermOrdersRead,
+	PermOrdersWrite,
+	PermPaymentsRead,
+	PermPaymentsWrite,
+	PermTransactionsRead,
+	PermCashIn,
+	PermCashOut,
+	PermBalanceRead,
+	PermBalanceWrite,
+	PermDashboardRead,
+	PermUsersManage,
+	PermPortalRead,
+	PermAPIKeysManage,
+	PermAuditRead,
+}
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: AllPermissions,
+	RoleCashier: {
+		PermResellersRead,
+		PermResellersWrite,
+		PermProductsRead,
+		PermOrdersRead,
+		PermOrdersWrite,
+		PermPaymentsRead,
+		PermPaymentsWrite,
+		PermTransactionsRead,
+		PermCashIn,
+		PermBalanceRead,
+		PermDashboardRead,
+	},
+	RoleWarehouse: {
+		PermProductsRead,
+		PermProductsWrite,
+	},
+	RoleReseller: {
+		PermPortalRead,
+	},
+}
+
+// IsValidScope reports whether the permission may be granted to an API key.
+// Keys can never manage users or other keys, nor act as a reseller.
+func (p Permission) IsValidScope() bool {
+	switch p {
+	case PermUsersManage, PermAPIKeysManage, PermPortalRead:
+		return false
+	}
+
+	for _, known := range AllPermissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid reports whether the role is one of the known roles
+func (r Role) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Permissions returns the permissions granted to the role
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// HasPermission reports whether the role grants the given permission
+func (r Role) HasPermission(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Sequence is a per-tenant, per-year counter used for gap-free document
+// numbers such as invoice numbers
+type Sequence struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID  string    `json:"-" gorm:"size:36;uniqueIndex:idx_sequences_scope"`
+	Name      string    `json:"name" gorm:"size:50;not null;uniqueIndex:idx_sequences_scope"`
+	Year      int       `json:"year" gorm:"not null;uniqueIndex:idx_sequences_scope"`
+	Value     int64     `json:"value" gorm:"not null;default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
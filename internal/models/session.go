@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Session represents a login session backed by a rotating refresh token
+type Session struct {
+	ID               string     `json:"id" gorm:"primarykey"`
+	UserID           string     `json:"user_id" gorm:"index;not null"`
+	RefreshTokenHash string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	AccessTokenID    string     `json:"-" gorm:"index;not null"` // jti of the access token issued with this session
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	ReplacedByID     *string    `json:"replaced_by_id"` // Set when the refresh token was rotated
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// IsActive reports whether the session can still be used
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// ShipmentStatus defines where a shipment is on its way to the reseller
+type ShipmentStatus string
+
+const (
+	ShipmentPending   ShipmentStatus = "pending"   // Packed, not yet handed to the courier
+	ShipmentShipped   ShipmentStatus = "shipped"   // With the courier
+	ShipmentDelivered ShipmentStatus = "delivered" // Received by the reseller
+)
+
+// shipmentStages orders the shipment statuses, a shipment only moves forward
+var shipmentStages = map[ShipmentStatus]int{
+	ShipmentPending:   0,
+	ShipmentShipped:   1,
+	ShipmentDelivered: 2,
+}
+
+// IsValid reports whether the status is one of the known shipment statuses
+func (s ShipmentStatus) IsValid() bool {
+	_, ok := shipmentStages[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a shipment may move from s to next
+func (s ShipmentStatus) CanTransitionTo(next ShipmentStatus) bool {
+	return next.IsValid() && shipmentStages[next] >= shipmentStages[s]
+}
+
+// Shipment represents goods of an order sent to the reseller by courier
+// @Description Shipment information
+type Shipment struct {
+	BaseModel
+	// ID of the shipped order
+	OrderID string `json:"order_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440001"`
+	// Name of the courier
+	Courier string `json:"courier" gorm:"size:100" example:"JNE"`
+	// Courier tracking number
+	TrackingNumber string `json:"tracking_number" gorm:"size:100;index" example:"JNE1234567890"`
+	// Cost of shipping
+	ShippingCost float64 `json:"shipping_cost" example:"25.00"`
+	// Status of the shipment
+	Status ShipmentStatus `json:"status" gorm:"size:20;not null;default:'pending'" example:"shipped"` // pending, shipped, delivered
+	// Date when the goods were handed to the courier
+	ShippedAt *time.Time `json:"shipped_at"`
+	// Date when the reseller received the goods
+	DeliveredAt *time.Time `json:"delivered_at"`
+	// Additional notes about the shipment
+	Notes string `json:"notes" example:"Fragile"`
+	// ID of the CASH_OUT transaction for the shipping cost, if recorded
+	TransactionID *string `json:"transaction_id" example:"550e8400-e29b-41d4-a716-446655440003"`
+}
+
+// CreateShipmentRequest describes a new shipment of an order
+type CreateShipmentRequest struct {
+	Courier        string         `json:"courier"`
+	TrackingNumber string         `json:"tracking_number"`
+	ShippingCost   float64        `json:"shipping_cost"`
+	Status         ShipmentStatus `json:"status"` // Defaults to pending
+	Notes          string         `json:"notes"`
+	// Record the shipping cost as a CASH_OUT expense
+	RecordExpense bool `json:"record_expense"`
+	// Complete the order when the shipment is delivered
+	CompleteOrder bool `json:"complete_order"`
+}
+
+// UpdateShipmentRequest changes a shipment. Omitted fields are left as they are.
+type UpdateShipmentRequest struct {
+	Courier        *string        `json:"courier"`
+	TrackingNumber *string        `json:"tracking_number"`
+	ShippingCost   *float64       `json:"shipping_cost"`
+	Status         ShipmentStatus `json:"status"`
+	Notes          *string        `json:"notes"`
+	// Record the shipping cost as a CASH_OUT expense
+	RecordExpense bool `json:"record_expense"`
+	// Complete the order when the shipment is delivered
+	CompleteOrder bool `json:"complete_order"`
+}
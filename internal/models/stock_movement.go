@@ -0,0 +1,45 @@
+package models
+
+// StockMovementReason defines why a product's stock changed
+type StockMovementReason string
+
+const (
+	StockRestock    StockMovementReason = "restock"
+	StockSale       StockMovementReason = "sale"
+	StockCancel     StockMovementReason = "cancel"
+	StockAdjustment StockMovementReason = "adjustment"
+	StockReturn     StockMovementReason = "return"
+)
+
+// StockMovement represents a single change to a product's stock
+// @Description Stock movement information
+type StockMovement struct {
+	BaseModel
+	// ID of the product whose stock changed
+	ProductID string `json:"product_id" gorm:"type:uuid;not null;index" example:"550e8400-e29b-41d4-a716-446655440002"`
+	// Signed quantity, positive when stock was added and negative when it was removed
+	Quantity int `json:"quantity" gorm:"not null" example:"-2"`
+	// Reason for the change
+	Reason StockMovementReason `json:"reason" gorm:"size:20;not null" example:"sale"` // restock, sale, cancel, adjustment, return
+	// ID of the record that caused the change, such as an order
+	ReferenceID *string `json:"reference_id" gorm:"index" example:"550e8400-e29b-41d4-a716-446655440001"`
+	// User who made the change, empty for system changes
+	UserID string `json:"user_id" gorm:"size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Product stock right after the change
+	ResultingStock int `json:"resulting_stock" example:"48"`
+	// Notes about the change
+	Notes string `json:"notes" example:"Supplier delivery"`
+}
+
+// StockCheck compares a product's stock with the sum of its movements
+// @Description Stock consistency check information
+type StockCheck struct {
+	// ID of the checked product
+	ProductID string `json:"product_id" example:"550e8400-e29b-41d4-a716-446655440002"`
+	// Stock stored on the product
+	CurrentStock int `json:"current_stock" example:"48"`
+	// Sum of all stock movements of the product
+	MovementTotal int `json:"movement_total" example:"48"`
+	// Whether the stored stock matches the movements
+	Consistent bool `json:"consistent" example:"true"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Tenant represents a business served by this deployment. Every record
+// embedding BaseModel belongs to exactly one tenant.
+// @Description Tenant information
+type Tenant struct {
+	// Unique identifier for the tenant
+	ID string `json:"id" gorm:"primarykey;size:36" example:"550e8400-e29b-41d4-a716-446655440000"`
+	// Business name
+	Name string `json:"name" gorm:"size:100;not null" example:"Toko Maju"`
+	// Creation timestamp
+	CreatedAt time.Time `json:"created_at"`
+	// Last update timestamp
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -18,6 +18,10 @@ const (
 	Salary     TransactionCategory = "SALARY"
 	Equipment  TransactionCategory = "EQUIPMENT"
 	PaymentTran TransactionCategory = "PAYMENT"  // Renamed from "Payment" to "PaymentTran" to avoid conflict with Payment model
+	Refund     TransactionCategory = "REFUND" // Money paid back to a reseller for returned goods
+	Shipping   TransactionCategory = "SHIPPING" // Courier costs of shipping orders
+	Reversal   TransactionCategory = "REVERSAL" // Compensating entry for a voided payment receipt
+	Deposit    TransactionCategory = "DEPOSIT" // Money a reseller keeps as credit, deposits and overpayments
 	Other      TransactionCategory = "OTHER"
 )
 
@@ -7,20 +7,24 @@ import (
 )
 
 type User struct {
-	ID        string         `json:"id" gorm:"primarykey"`
-	Name      string         `json:"name" gorm:"size:100;not null"`
-	Email     string         `json:"email" gorm:"size:255;uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID         string         `json:"id" gorm:"primarykey"`
+	TenantID   string         `json:"tenant_id" gorm:"size:36;index"`
+	Name       string         `json:"name" gorm:"size:100;not null"`
+	Email      string         `json:"email" gorm:"size:255;uniqueIndex;not null"`
+	Password   string         `json:"-" gorm:"not null"`
+	Role       Role           `json:"role" gorm:"size:20"`
+	ResellerID *string        `json:"reseller_id,omitempty" gorm:"type:uuid;index"` // Only set for the reseller role
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // Auth Requests & Responses
 type RegisterRequest struct {
-	Name     string `json:"name" validate:"required,min=2"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Name         string `json:"name" validate:"required,min=2"`
+	Email        string `json:"email" validate:"required,email"`
+	Password     string `json:"password" validate:"required,min=6"`
+	BusinessName string `json:"business_name"` // Name of the new tenant, defaults to the user's name
 }
 
 type LoginRequest struct {
@@ -28,7 +32,26 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// User Management Requests
+type CreateUserRequest struct {
+	Name       string  `json:"name" validate:"required,min=2"`
+	Email      string  `json:"email" validate:"required,email"`
+	Password   string  `json:"password" validate:"required,min=6"`
+	Role       Role    `json:"role" validate:"required"`
+	ResellerID *string `json:"reseller_id,omitempty"` // Required for the reseller role
+}
+
+type UpdateRoleRequest struct {
+	Role Role `json:"role" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
 type AuthResponse struct {
-	User        *User  `json:"user"`
-	AccessToken string `json:"access_token"`
+	User         *User  `json:"user"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // Access token lifetime in seconds
 }
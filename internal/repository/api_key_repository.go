@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) interfaces.APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (a *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return a.db.WithContext(ctx).Create(key).Error
+}
+
+func (a *apiKeyRepository) FindAll(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := a.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (a *apiKeyRepository) FindByID(ctx context.Context, id string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := a.db.WithContext(ctx).Where("id = ?", id).First(&key).Error
+	return &key, err
+}
+
+func (a *apiKeyRepository) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := a.db.WithContext(ctx).Where("key_hash = ?", hash).First(&key).Error
+	return &key, err
+}
+
+func (a *apiKeyRepository) Revoke(ctx context.Context, id string) error {
+	return a.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (a *apiKeyRepository) TouchLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	// Usage timestamps change on every request and are not worth auditing
+	return a.db.WithContext(utils.WithoutAudit(ctx)).Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"gorm.io/gorm"
+)
+
+// auditLogRepository only reads audit logs; entries are written by the
+// audit callbacks registered on the database
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) interfaces.AuditLogRepository {
+	return &auditLogRepository{
+		db: db,
+	}
+}
+
+func (a *auditLogRepository) FindAll(ctx context.Context, filter *models.AuditLogFilter) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	query := a.db.WithContext(ctx).Order("created_at DESC")
+
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+
+	err := query.Limit(filter.Limit).Offset(filter.Offset).Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type bankStatementRepository struct {
+	db *gorm.DB
+}
+
+func NewBankStatementRepository(db *gorm.DB) *bankStatementRepository {
+	return &bankStatementRepository{db: db}
+}
+
+// Create saves a statement together with its lines
+func (r *bankStatementRepository) Create(ctx context.Context, statement *models.BankStatement) error {
+	if statement.ImportedBy == "" {
+		statement.ImportedBy = actorID(ctx)
+	}
+	return r.db.WithContext(ctx).Create(statement).Error
+}
+
+func (r *bankStatementRepository) GetAll(ctx context.Context) ([]models.BankStatement, error) {
+	var statements []models.BankStatement
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&statements).Error
+	return statements, err
+}
+
+func (r *bankStatementRepository) GetByID(ctx context.Context, id string) (*models.BankStatement, error) {
+	var statement models.BankStatement
+	err := r.db.WithContext(ctx).Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date ASC").Order("created_at ASC")
+	}).Where("id = ?", id).First(&statement).Error
+	return &statement, err
+}
+
+// GetImportedFingerprints reports which of the fingerprints belong to lines
+// of statements imported before
+func (r *bankStatementRepository) GetImportedFingerprints(ctx context.Context, fingerprints []string) (map[string]bool, error) {
+	imported := make(map[string]bool)
+	if len(fingerprints) == 0 {
+		return imported, nil
+	}
+
+	var found []string
+	err := r.db.WithContext(ctx).Model(&models.BankStatementLine{}).
+		Where("fingerprint IN ?", fingerprints).Pluck("fingerprint", &found).Error
+	for _, fingerprint := range found {
+		imported[fingerprint] = true
+	}
+	return imported, err
+}
+
+// GetLineForUpdate loads a line of a statement and locks it until the
+// surrounding transaction ends
+func (r *bankStatementRepository) GetLineForUpdate(ctx context.Context, statementID string, id string) (*models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND statement_id = ?", id, statementID).First(&line).Error
+	return &line, err
+}
+
+// DecideLine stores the decision made on a line
+func (r *bankStatementRepository) DecideLine(ctx context.Context, line *models.BankStatementLine) error {
+	if line.DecidedBy == "" {
+		line.DecidedBy = actorID(ctx)
+	}
+	return r.db.WithContext(ctx).Model(&models.BankStatementLine{}).Where("id = ?", line.ID).Updates(map[string]interface{}{
+		"status":     line.Status,
+		"order_id":   line.OrderID,
+		"receipt_id": line.ReceiptID,
+		"decided_by": line.DecidedBy,
+		"decided_at": line.DecidedAt,
+	}).Error
+}
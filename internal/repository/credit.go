@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type creditRepository struct {
+	db *gorm.DB
+}
+
+func NewCreditRepository(db *gorm.DB) *creditRepository {
+	return &creditRepository{db: db}
+}
+
+func (r *creditRepository) Create(ctx context.Context, entry *models.ResellerCreditEntry) error {
+	if entry.UserID == "" {
+		entry.UserID = actorID(ctx)
+	}
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+func (r *creditRepository) GetBalance(ctx context.Context, resellerID string) (float64, error) {
+	var balance float64
+	err := r.db.WithContext(ctx).Model(&models.ResellerCreditEntry{}).
+		Where("reseller_id = ?", resellerID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// GetBalanceForUpdate locks the reseller until the surrounding transaction
+// ends and returns its credit balance, so concurrent spending of the same
+// credit is serialized
+func (r *creditRepository) GetBalanceForUpdate(ctx context.Context, resellerID string) (float64, error) {
+	var reseller models.Reseller
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").Where("id = ?", resellerID).First(&reseller).Error
+	if err != nil {
+		return 0, err
+	}
+	return r.GetBalance(ctx, resellerID)
+}
+
+// GetEntries lists the credit entries of a reseller, oldest first
+func (r *creditRepository) GetEntries(ctx context.Context, resellerID string) ([]models.ResellerCreditEntry, error) {
+	var entries []models.ResellerCreditEntry
+	err := r.db.WithContext(ctx).Where("reseller_id = ?", resellerID).Order("created_at ASC").Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type idempotencyKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) interfaces.IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{
+		db: db,
+	}
+}
+
+// Reserve stores a new key and reports whether it was stored. It stores
+// nothing when the tenant already has the key, so only one of several
+// concurrent requests with the same key gets to run.
+func (r *idempotencyKeyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "key"}},
+		DoNothing: true,
+	}).Create(key)
+	return result.RowsAffected == 1, result.Error
+}
+
+// FindByKey finds a stored key, or returns utils.ErrIdempotencyKeyNotFound
+func (r *idempotencyKeyRepository) FindByKey(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.WithContext(ctx).Where("key = ?", key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, utils.ErrIdempotencyKeyNotFound
+	}
+	return &record, err
+}
+
+func (r *idempotencyKeyRepository) SaveResponse(ctx context.Context, id string, statusCode int, contentType string, response []byte) error {
+	return r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status_code":  statusCode,
+		"content_type": contentType,
+		"response":     response,
+	}).Error
+}
+
+func (r *idempotencyKeyRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&models.IdempotencyKey{}, "id = ?", id).Error
+}
+
+func (r *idempotencyKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
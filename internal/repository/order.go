@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type orderRepository struct {
@@ -13,36 +18,142 @@ func NewOrderRepository(db *gorm.DB) *orderRepository {
 	return &orderRepository{db: db}
 }
 
-func (r *orderRepository) Create(order *models.Order) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+func (r *orderRepository) Create(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Create the order
 		if err := tx.Create(order).Error; err != nil {
 			return err
 		}
 
-		return nil
+		// Start the order's timeline
+		return tx.Create(&models.OrderStatusHistory{
+			OrderID:  order.ID,
+			ToStatus: order.Status,
+			UserID:   actorID(ctx),
+		}).Error
 	})
 
 }
 
-func (r *orderRepository) GetAll() ([]models.Order, error) {
+// GetAll lists orders, optionally narrowed to those whose order or invoice
+// number contains search
+func (r *orderRepository) GetAll(ctx context.Context, search string) ([]models.Order, error) {
 	var orders []models.Order
-	err := r.db.Preload("Reseller").Preload("OrderItems").Preload("OrderItems.Product").Preload("Payment").Find(&orders).Error
+	query := r.db.WithContext(ctx).Preload("Reseller").Preload("OrderItems").Preload("OrderItems.Product").Preload("Payment")
+	if search != "" {
+		pattern := "%" + search + "%"
+		query = query.Where("order_number ILIKE ? OR invoice_number ILIKE ?", pattern, pattern)
+	}
+	err := query.Order("created_at DESC").Find(&orders).Error
 	return orders, err
 }
 
-func (r *orderRepository) GetByID(id string) (*models.Order, error) {
+// GetByID finds an order by its UUID or its order number
+func (r *orderRepository) GetByID(ctx context.Context, id string) (*models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Preload("Reseller").Preload("OrderItems").Preload("OrderItems.Product").Preload("Payment").Preload("Shipments").Where(orderKey(id)+" = ?", id).First(&order).Error
+	return &order, err
+}
+
+// orderKey returns the column an order reference is matched against. Orders
+// can be referred to by UUID or by order number.
+func orderKey(id string) string {
+	if utils.ValidateUUID(id) {
+		return "id"
+	}
+	return "order_number"
+}
+
+// GetForUpdate loads an order with its items and payment and locks the order
+// row until the surrounding transaction ends
+func (r *orderRepository) GetForUpdate(ctx context.Context, id string) (*models.Order, error) {
 	var order models.Order
-	err := r.db.Preload("Reseller").Preload("OrderItems").Preload("OrderItems.Product").Preload("Payment").Where("id = ?", id).First(&order).Error
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("OrderItems").Preload("Payment").
+		Where(orderKey(id)+" = ?", id).First(&order).Error
 	return &order, err
 }
 
-func (r *orderRepository) Update(id string, order *models.Order) error {
-	return r.db.Model(&models.Order{}).Where("id = ?", id).Updates(order).Error
+func (r *orderRepository) CreateItem(ctx context.Context, item *models.OrderItem) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r *orderRepository) UpdateItem(ctx context.Context, item *models.OrderItem) error {
+	return r.db.WithContext(ctx).Model(&models.OrderItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"quantity":        item.Quantity,
+		"subtotal":        item.Subtotal,
+		"discount_amount": item.DiscountAmount,
+	}).Error
 }
 
-func (r *orderRepository) Delete(id string) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+func (r *orderRepository) DeleteItem(ctx context.Context, itemID string) error {
+	return r.db.WithContext(ctx).Delete(&models.OrderItem{}, "id = ?", itemID).Error
+}
+
+// UpdateTotals stores recalculated order amounts on the order and the grand
+// total on its payment
+func (r *orderRepository) UpdateTotals(ctx context.Context, order *models.Order, paymentStatus string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+			"subtotal":        order.Subtotal,
+			"discount_amount": order.DiscountAmount,
+			"tax_amount":      order.TaxAmount,
+			"total_amount":    order.TotalAmount,
+			"payment_status":  paymentStatus,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Payment{}).Where("order_id = ?", order.ID).Updates(map[string]interface{}{
+			"total_amount": order.TotalAmount,
+			"status":       paymentStatus,
+		}).Error
+	})
+}
+
+func (r *orderRepository) UpdateItemReturned(ctx context.Context, item *models.OrderItem) error {
+	return r.db.WithContext(ctx).Model(&models.OrderItem{}).Where("id = ?", item.ID).
+		Update("returned_quantity", item.ReturnedQuantity).Error
+}
+
+func (r *orderRepository) CreateReturn(ctx context.Context, orderReturn *models.OrderReturn) error {
+	if orderReturn.UserID == "" {
+		orderReturn.UserID = actorID(ctx)
+	}
+	return r.db.WithContext(ctx).Create(orderReturn).Error
+}
+
+func (r *orderRepository) GetReturns(ctx context.Context, orderID string) ([]models.OrderReturn, error) {
+	var returns []models.OrderReturn
+	err := r.db.WithContext(ctx).Preload("Items").Where("order_id = ?", orderID).Order("created_at DESC").Find(&returns).Error
+	return returns, err
+}
+
+// SetInvoiceNumber assigns an invoice number to an order that has none yet
+func (r *orderRepository) SetInvoiceNumber(ctx context.Context, id string, invoiceNumber string, invoicedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("id = ? AND invoice_number IS NULL", id).
+		Updates(map[string]interface{}{
+			"invoice_number": invoiceNumber,
+			"invoiced_at":    invoicedAt,
+		}).Error
+}
+
+// editableOrderColumns are the order columns users may edit directly. Status,
+// totals, payment status, due date and invoice number change through their
+// own flows, so a column added later stays read-only unless listed here.
+var editableOrderColumns = []string{"notes", "order_date"}
+
+// Update saves the editable columns of an order. Line items, payment and
+// shipments have their own endpoints and are never saved through it.
+func (r *orderRepository) Update(ctx context.Context, id string, order *models.Order) error {
+	return r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ?", id).
+		Select(editableOrderColumns).Omit(clause.Associations).Updates(order).Error
+}
+
+func (r *orderRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Delete order items first
 		if err := tx.Where("order_id = ?", id).Delete(&models.OrderItem{}).Error; err != nil {
 			return err
@@ -53,8 +164,40 @@ func (r *orderRepository) Delete(id string) error {
 	})
 }
 
-func (r *orderRepository) Cancel(id string) error {
-	return r.db.Transaction(func(tx *gorm.DB) error {
+// UpdateStatus moves an order from one status to another and records the
+// change in the order's timeline. It returns utils.ErrOrderStatusChanged when
+// the order is no longer in the from status.
+func (r *orderRepository) UpdateStatus(ctx context.Context, id string, from, to models.OrderStatus, notes string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrOrderStatusChanged
+		}
+
+		return tx.Create(&models.OrderStatusHistory{
+			OrderID:    id,
+			FromStatus: from,
+			ToStatus:   to,
+			UserID:     actorID(ctx),
+			Notes:      notes,
+		}).Error
+	})
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, id string) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	err := r.db.WithContext(ctx).Where("order_id = ?", id).Order("created_at ASC").Find(&history).Error
+	return history, err
+}
+
+// Cancel restores the stock of a cancelled order's items and marks its
+// payment status cancelled. The order status itself is changed through
+// UpdateStatus.
+func (r *orderRepository) Cancel(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Get the order to access its items
 		var order models.Order
 		err := tx.Preload("OrderItems").Where("id = ?", id).First(&order).Error
@@ -62,15 +205,21 @@ func (r *orderRepository) Cancel(id string) error {
 			return err
 		}
 
-		// Update order status to cancelled
-		if err := tx.Model(&models.Order{}).Where("id = ?", id).Update("status", "cancelled").Error; err != nil {
+		// Update payment status to cancelled
+		err = tx.Model(&models.Order{}).Where("id = ?", id).Update("payment_status", "cancelled").Error
+		if err != nil {
 			return err
 		}
 
 		// Restore stock for each order item
 		for _, item := range order.OrderItems {
-			if err := tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
-				UpdateColumn("current_stock", gorm.Expr("current_stock + ?", item.Quantity)).Error; err != nil {
+			err := moveStock(tx, &models.StockMovement{
+				ProductID:   item.ProductID,
+				Quantity:    item.Quantity,
+				Reason:      models.StockCancel,
+				ReferenceID: &order.ID,
+			})
+			if err != nil {
 				return err
 			}
 		}
@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
+	"math"
 	"time"
 
 	"github.com/aryadhira/reseller-management/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DashboardData struct {
@@ -28,39 +31,128 @@ func NewPaymentRepository(db *gorm.DB) *paymentRepository {
 	return &paymentRepository{db: db}
 }
 
-func (r *paymentRepository) GetAll() ([]models.Payment, error) {
+func (r *paymentRepository) GetAll(ctx context.Context) ([]models.Payment, error) {
 	var payments []models.Payment
-	err := r.db.Preload("Order").Preload("Order.Reseller").Find(&payments).Error
+	err := r.db.WithContext(ctx).Preload("Order").Preload("Order.Reseller").Preload("Receipts", orderReceipts).Find(&payments).Error
 	return payments, err
 }
 
-func (r *paymentRepository) GetByOrderID(orderID string) (*models.Payment, error) {
+// GetByOrderID finds the payment of an order given the order's UUID or its
+// order number
+func (r *paymentRepository) GetByOrderID(ctx context.Context, orderID string) (*models.Payment, error) {
 	var payment models.Payment
-	err := r.db.Preload("Order").Preload("Order.Reseller").Where("order_id = ?", orderID).First(&payment).Error
+	query := r.db.WithContext(ctx).Preload("Order").Preload("Order.Reseller").Preload("Receipts", orderReceipts)
+	if orderKey(orderID) == "id" {
+		query = query.Where("order_id = ?", orderID)
+	} else {
+		query = query.Where("order_id IN (?)", r.db.WithContext(ctx).Model(&models.Order{}).Select("id").Where("order_number = ?", orderID))
+	}
+	err := query.First(&payment).Error
 	return &payment, err
 }
 
-func (r *paymentRepository) Create(payment *models.Payment) error {
-	return r.db.Create(payment).Error
+// GetByOrderIDs finds the payments of several orders given their UUIDs.
+// Orders without a payment are left out.
+func (r *paymentRepository) GetByOrderIDs(ctx context.Context, orderIDs []string) ([]models.Payment, error) {
+	payments := []models.Payment{}
+	if len(orderIDs) == 0 {
+		return payments, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Order").Preload("Order.Reseller").Preload("Receipts", orderReceipts).
+		Where("order_id IN ?", orderIDs).Find(&payments).Error
+	return payments, err
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Create(payment).Error
+}
+
+func (r *paymentRepository) Update(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Save(payment).Error
+}
+
+// CancelByOrderID marks the payment of a cancelled order cancelled. The
+// receipts of the order are voided beforehand, so nothing is left paid on it.
+func (r *paymentRepository) CancelByOrderID(ctx context.Context, orderID string) error {
+	return r.db.WithContext(ctx).Model(&models.Payment{}).Where("order_id = ?", orderID).Updates(map[string]interface{}{
+		"status":      "cancelled",
+		"amount_paid": 0,
+	}).Error
+}
+
+func (r *paymentRepository) CreateReceipt(ctx context.Context, receipt *models.PaymentReceipt) error {
+	if receipt.RecordedBy == "" {
+		receipt.RecordedBy = actorID(ctx)
+	}
+	return r.db.WithContext(ctx).Create(receipt).Error
+}
+
+// GetReceiptForUpdate finds a payment receipt and locks it until the
+// surrounding transaction ends
+func (r *paymentRepository) GetReceiptForUpdate(ctx context.Context, id string) (*models.PaymentReceipt, error) {
+	var receipt models.PaymentReceipt
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).First(&receipt).Error
+	return &receipt, err
 }
 
-func (r *paymentRepository) Update(payment *models.Payment) error {
-	return r.db.Save(payment).Error
+// VoidReceipt stores the void details of a receipt, the receipt itself is
+// kept so it stays visible in the payment history
+func (r *paymentRepository) VoidReceipt(ctx context.Context, receipt *models.PaymentReceipt) error {
+	if receipt.VoidedBy == "" {
+		receipt.VoidedBy = actorID(ctx)
+	}
+	return r.db.WithContext(ctx).Model(&models.PaymentReceipt{}).Where("id = ?", receipt.ID).Updates(map[string]interface{}{
+		"voided_at":               receipt.VoidedAt,
+		"voided_by":               receipt.VoidedBy,
+		"void_reason":             receipt.VoidReason,
+		"reversal_transaction_id": receipt.ReversalTransactionID,
+	}).Error
+}
+
+// RefreshAmountPaid derives the amount paid for an order from its receipts
+// that are not voided, less what returns refunded or credited to the
+// reseller, stores it on the payment and returns it
+func (r *paymentRepository) RefreshAmountPaid(ctx context.Context, orderID string) (float64, error) {
+	var amountPaid float64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var received, settled float64
+		err := tx.Model(&models.PaymentReceipt{}).Where("order_id = ? AND voided_at IS NULL", orderID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&received).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.OrderReturn{}).Where("order_id = ?", orderID).
+			Select("COALESCE(SUM(settled_amount), 0)").Scan(&settled).Error
+		if err != nil {
+			return err
+		}
+
+		amountPaid = math.Max(0, received-settled)
+		return tx.Model(&models.Payment{}).Where("order_id = ?", orderID).Update("amount_paid", amountPaid).Error
+	})
+	return amountPaid, err
 }
 
-func (r *paymentRepository) GetAllTransactions() ([]models.Transaction, error) {
+// orderReceipts sorts preloaded receipts by the date they were received
+func orderReceipts(db *gorm.DB) *gorm.DB {
+	return db.Order("received_at ASC")
+}
+
+func (r *paymentRepository) GetAllTransactions(ctx context.Context) ([]models.Transaction, error) {
 	var transactions []models.Transaction
-	err := r.db.Order("created_at DESC").Find(&transactions).Error
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&transactions).Error
 	return transactions, err
 }
 
-func (r *paymentRepository) CreateTransaction(transaction *models.Transaction) error {
-	return r.db.Create(transaction).Error
+func (r *paymentRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return r.db.WithContext(ctx).Create(transaction).Error
 }
 
-func (r *paymentRepository) UpdateBalance(initialBalance float64) error {
+func (r *paymentRepository) UpdateBalance(ctx context.Context, initialBalance float64) error {
 	var balance models.Balance
-	err := r.db.First(&balance).Error
+	err := r.db.WithContext(ctx).First(&balance).Error
 	if err != nil {
 		// If no balance record exists, create one
 		if err == gorm.ErrRecordNotFound {
@@ -68,7 +160,7 @@ func (r *paymentRepository) UpdateBalance(initialBalance float64) error {
 				InitialBalance: initialBalance,
 				CurrentBalance: initialBalance,
 			}
-			return r.db.Create(&balance).Error
+			return r.db.WithContext(ctx).Create(&balance).Error
 		}
 		return err
 	}
@@ -78,16 +170,49 @@ func (r *paymentRepository) UpdateBalance(initialBalance float64) error {
 	balance.CurrentBalance = initialBalance // Reset current balance to initial
 
 	// Recalculate current balance from all transactions
-	cashInTotal := r.calculateCashInTotal()
-	cashOutTotal := r.calculateCashOutTotal()
+	cashInTotal := r.calculateCashInTotal(ctx)
+	cashOutTotal := r.calculateCashOutTotal(ctx)
 	balance.CurrentBalance = balance.InitialBalance + cashInTotal - cashOutTotal
 
-	return r.db.Save(&balance).Error
+	return r.db.WithContext(ctx).Save(&balance).Error
+}
+
+// AdjustInitialBalance changes the initial balance and records the change.
+// The old value and delta of the adjustment are filled in from the stored
+// balance, which is locked until the adjustment is saved.
+func (r *paymentRepository) AdjustInitialBalance(ctx context.Context, adjustment *models.BalanceAdjustment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txRepo := &paymentRepository{db: tx}
+
+		var balance models.Balance
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&balance).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		adjustment.OldValue = balance.InitialBalance
+		adjustment.Delta = adjustment.NewValue - balance.InitialBalance
+		if err := tx.Create(adjustment).Error; err != nil {
+			return err
+		}
+
+		balance.InitialBalance = adjustment.NewValue
+		balance.CurrentBalance = adjustment.NewValue + txRepo.calculateCashInTotal(ctx) - txRepo.calculateCashOutTotal(ctx)
+		balance.Notes = adjustment.Notes
+
+		return tx.Save(&balance).Error
+	})
+}
+
+func (r *paymentRepository) GetBalanceAdjustments(ctx context.Context) ([]models.BalanceAdjustment, error) {
+	var adjustments []models.BalanceAdjustment
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&adjustments).Error
+	return adjustments, err
 }
 
-func (r *paymentRepository) GetBalance() (*models.Balance, error) {
+func (r *paymentRepository) GetBalance(ctx context.Context) (*models.Balance, error) {
 	var balance models.Balance
-	err := r.db.First(&balance).Error
+	err := r.db.WithContext(ctx).First(&balance).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// If no balance record exists, create one with default values
@@ -95,7 +220,7 @@ func (r *paymentRepository) GetBalance() (*models.Balance, error) {
 				InitialBalance: 0,
 				CurrentBalance: 0,
 			}
-			err = r.db.Create(&balance).Error
+			err = r.db.WithContext(ctx).Create(&balance).Error
 			if err != nil {
 				return nil, err
 			}
@@ -104,62 +229,62 @@ func (r *paymentRepository) GetBalance() (*models.Balance, error) {
 		}
 	} else {
 		// Recalculate current balance from all transactions
-		cashInTotal := r.calculateCashInTotal()
-		cashOutTotal := r.calculateCashOutTotal()
+		cashInTotal := r.calculateCashInTotal(ctx)
+		cashOutTotal := r.calculateCashOutTotal(ctx)
 		balance.CurrentBalance = balance.InitialBalance + cashInTotal - cashOutTotal
 		
 		// Save the updated balance
-		r.db.Save(&balance)
+		r.db.WithContext(ctx).Save(&balance)
 	}
 
 	return &balance, nil
 }
 
-func (r *paymentRepository) GetDashboardData() (*DashboardData, error) {
+func (r *paymentRepository) GetDashboardData(ctx context.Context) (*DashboardData, error) {
 	today := time.Now().Truncate(24 * time.Hour)
 	thisMonth := time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.Now().Location())
 
-	balance, err := r.GetBalance()
+	balance, err := r.GetBalance(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	todayCashIn, err := r.GetCashInByDateRange(today, today.Add(24*time.Hour))
+	todayCashIn, err := r.GetCashInByDateRange(ctx, today, today.Add(24*time.Hour))
 	if err != nil {
 		return nil, err
 	}
 
-	thisMonthCashIn, err := r.GetCashInByDateRange(thisMonth, thisMonth.AddDate(0, 1, 0))
+	thisMonthCashIn, err := r.GetCashInByDateRange(ctx, thisMonth, thisMonth.AddDate(0, 1, 0))
 	if err != nil {
 		return nil, err
 	}
 
-	allTimeCashIn, err := r.GetCashInByDateRange(time.Time{}, time.Now().Add(24*time.Hour))
+	allTimeCashIn, err := r.GetCashInByDateRange(ctx, time.Time{}, time.Now().Add(24*time.Hour))
 	if err != nil {
 		return nil, err
 	}
 
-	todayCashOut, err := r.GetCashOutByDateRange(today, today.Add(24*time.Hour))
+	todayCashOut, err := r.GetCashOutByDateRange(ctx, today, today.Add(24*time.Hour))
 	if err != nil {
 		return nil, err
 	}
 
-	thisMonthCashOut, err := r.GetCashOutByDateRange(thisMonth, thisMonth.AddDate(0, 1, 0))
+	thisMonthCashOut, err := r.GetCashOutByDateRange(ctx, thisMonth, thisMonth.AddDate(0, 1, 0))
 	if err != nil {
 		return nil, err
 	}
 
-	allTimeCashOut, err := r.GetCashOutByDateRange(time.Time{}, time.Now().Add(24*time.Hour))
+	allTimeCashOut, err := r.GetCashOutByDateRange(ctx, time.Time{}, time.Now().Add(24*time.Hour))
 	if err != nil {
 		return nil, err
 	}
 
-	recentTransactions, err := r.GetRecentTransactions(10)
+	recentTransactions, err := r.GetRecentTransactions(ctx, 10)
 	if err != nil {
 		return nil, err
 	}
 
-	unpaidOrders, err := r.GetUnpaidOrders()
+	unpaidOrders, err := r.GetUnpaidOrders(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -185,48 +310,98 @@ func (r *paymentRepository) GetDashboardData() (*DashboardData, error) {
 	return data, nil
 }
 
-func (r *paymentRepository) GetRecentTransactions(limit int) ([]models.Transaction, error) {
+func (r *paymentRepository) GetRecentTransactions(ctx context.Context, limit int) ([]models.Transaction, error) {
 	var transactions []models.Transaction
-	err := r.db.Order("created_at DESC").Limit(limit).Find(&transactions).Error
+	err := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Find(&transactions).Error
 	return transactions, err
 }
 
-func (r *paymentRepository) GetCashInByDateRange(start, end time.Time) (float64, error) {
+func (r *paymentRepository) GetCashInByDateRange(ctx context.Context, start, end time.Time) (float64, error) {
 	var total float64
-	err := r.db.Model(&models.Transaction{}).
+	err := r.db.WithContext(ctx).Model(&models.Transaction{}).
 		Where("type = ? AND date >= ? AND date < ?", "CASH_IN", start, end).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&total).Error
 	return total, err
 }
 
-func (r *paymentRepository) GetCashOutByDateRange(start, end time.Time) (float64, error) {
+func (r *paymentRepository) GetCashOutByDateRange(ctx context.Context, start, end time.Time) (float64, error) {
 	var total float64
-	err := r.db.Model(&models.Transaction{}).
+	err := r.db.WithContext(ctx).Model(&models.Transaction{}).
 		Where("type = ? AND date >= ? AND date < ?", "CASH_OUT", start, end).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&total).Error
 	return total, err
 }
 
-func (r *paymentRepository) GetUnpaidOrders() ([]models.Order, error) {
+// GetUnpaidOrders lists orders that are not fully paid, the longest overdue
+// first and orders without a due date last
+func (r *paymentRepository) GetUnpaidOrders(ctx context.Context) ([]models.Order, error) {
 	var orders []models.Order
-	err := r.db.Preload("Reseller").Preload("OrderItems").Where("payment_status IN ?", []string{"unpaid", "partially_paid"}).Find(&orders).Error
+	err := r.db.WithContext(ctx).Preload("Reseller").Preload("OrderItems").Preload("Payment").
+		Where("payment_status IN ?", []string{"unpaid", "partially_paid", "overdue"}).
+		Order("due_date ASC NULLS LAST").Order("order_date ASC").
+		Find(&orders).Error
+
+	now := time.Now()
+	for i := range orders {
+		orders[i].DaysOverdue = orders[i].DaysPastDue(now)
+	}
+	return orders, err
+}
+
+// GetUnpaidOrdersByReseller lists the orders of a reseller that are not fully
+// paid, in the same order as GetUnpaidOrders
+func (r *paymentRepository) GetUnpaidOrdersByReseller(ctx context.Context, resellerID string) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.WithContext(ctx).
+		Where("reseller_id = ? AND payment_status IN ?", resellerID, []string{"unpaid", "partially_paid", "overdue"}).
+		Order("due_date ASC NULLS LAST").Order("order_date ASC").
+		Find(&orders).Error
 	return orders, err
 }
 
-func (r *paymentRepository) calculateCashInTotal() float64 {
+// MarkOverdue flags unpaid and partially paid orders whose due date passed
+// before now as overdue, together with their payments, and returns how many
+// orders were flagged. The status is checked in the update itself, so an order
+// paid while the job runs keeps its new status.
+func (r *paymentRepository) MarkOverdue(ctx context.Context, now time.Time) (int64, error) {
+	open := []string{"unpaid", "partially_paid"}
+
+	var marked int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var orders []models.Order
+		result := tx.Model(&orders).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+			Where("payment_status IN ? AND due_date < ?", open, now).
+			Update("payment_status", "overdue")
+		if result.Error != nil || len(orders) == 0 {
+			return result.Error
+		}
+		marked = int64(len(orders))
+
+		// The flagged orders stay locked until commit, so their payments
+		// cannot change in between
+		orderIDs := make([]string, 0, len(orders))
+		for _, order := range orders {
+			orderIDs = append(orderIDs, order.ID)
+		}
+		return tx.Model(&models.Payment{}).Where("order_id IN ? AND status IN ?", orderIDs, open).Update("status", "overdue").Error
+	})
+	return marked, err
+}
+
+func (r *paymentRepository) calculateCashInTotal(ctx context.Context) float64 {
 	var total float64
-	r.db.Model(&models.Transaction{}).
+	r.db.WithContext(ctx).Model(&models.Transaction{}).
 		Where("type = ?", "CASH_IN").
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&total)
 	return total
 }
 
-func (r *paymentRepository) calculateCashOutTotal() float64 {
+func (r *paymentRepository) calculateCashOutTotal(ctx context.Context) float64 {
 	var total float64
-	r.db.Model(&models.Transaction{}).
+	r.db.WithContext(ctx).Model(&models.Transaction{}).
 		Where("type = ?", "CASH_OUT").
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&total)
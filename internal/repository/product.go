@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -13,36 +16,117 @@ func NewProductRepository(db *gorm.DB) *productRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) Create(product *models.Product) error {
-	return r.db.Create(product).Error
+func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+
+		if product.CurrentStock == 0 {
+			return nil
+		}
+
+		// Record the opening stock so the ledger adds up to the current stock
+		return tx.Create(&models.StockMovement{
+			ProductID:      product.ID,
+			Quantity:       product.CurrentStock,
+			Reason:         models.StockAdjustment,
+			UserID:         actorID(ctx),
+			ResultingStock: product.CurrentStock,
+			Notes:          "Initial stock",
+		}).Error
+	})
 }
 
-func (r *productRepository) GetAll() ([]models.Product, error) {
+func (r *productRepository) GetAll(ctx context.Context) ([]models.Product, error) {
 	var products []models.Product
-	err := r.db.Find(&products).Error
+	err := r.db.WithContext(ctx).Find(&products).Error
 	return products, err
 }
 
-func (r *productRepository) GetByID(id string) (*models.Product, error) {
+func (r *productRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
 	var product models.Product
-	err := r.db.Where("id = ?", id).First(&product).Error
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&product).Error
 	return &product, err
 }
 
-func (r *productRepository) Update(id string, product *models.Product) error {
-	return r.db.Model(&models.Product{}).Where("id = ?", id).Updates(product).Error
+func (r *productRepository) Update(ctx context.Context, id string, product *models.Product) error {
+	// Stock only changes through stock movements
+	return r.db.WithContext(ctx).Model(&models.Product{}).Where("id = ?", id).Omit("current_stock").Updates(product).Error
+}
+
+func (r *productRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&models.Product{}, "id = ?", id).Error
 }
 
-func (r *productRepository) Delete(id string) error {
-	return r.db.Delete(&models.Product{}, "id = ?", id).Error
+func (r *productRepository) MoveStock(ctx context.Context, movement *models.StockMovement) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return moveStock(tx, movement)
+	})
 }
 
-func (r *productRepository) Restock(id string, quantity int) error {
-	return r.db.Model(&models.Product{}).Where("id = ?", id).UpdateColumn("current_stock", gorm.Expr("current_stock + ?", quantity)).Error
+func (r *productRepository) GetMovements(ctx context.Context, productID string) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	err := r.db.WithContext(ctx).Where("product_id = ?", productID).Order("created_at DESC").Find(&movements).Error
+	return movements, err
 }
 
-func (r *productRepository) GetLowStock() ([]models.Product, error) {
+func (r *productRepository) GetMovementTotal(ctx context.Context, productID string) (int, error) {
+	var total int
+	err := r.db.WithContext(ctx).Model(&models.StockMovement{}).
+		Where("product_id = ?", productID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *productRepository) GetLowStock(ctx context.Context) ([]models.Product, error) {
 	var products []models.Product
-	err := r.db.Where("current_stock <= min_stock_alert AND status = 'active'").Find(&products).Error
+	err := r.db.WithContext(ctx).Where("current_stock <= min_stock_alert AND status = 'active'").Find(&products).Error
 	return products, err
-}
\ No newline at end of file
+}
+
+// moveStock applies a stock movement to its product and records it with the
+// resulting stock. Stock is never taken below zero; utils.ErrInsufficientStock
+// is returned instead. It must run inside a transaction; the update locks the
+// product row until the transaction ends.
+func moveStock(tx *gorm.DB, movement *models.StockMovement) error {
+	query := tx.Model(&models.Product{}).Where("id = ?", movement.ProductID)
+	if movement.Quantity < 0 {
+		// Only take stock that is still available, even under concurrent orders
+		query = query.Where("current_stock >= ?", -movement.Quantity)
+	}
+
+	result := query.UpdateColumn("current_stock", gorm.Expr("current_stock + ?", movement.Quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := tx.Model(&models.Product{}).Where("id = ?", movement.ProductID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return utils.ErrInsufficientStock
+	}
+
+	var product models.Product
+	if err := tx.Select("current_stock").Where("id = ?", movement.ProductID).First(&product).Error; err != nil {
+		return err
+	}
+
+	movement.ResultingStock = product.CurrentStock
+	if movement.UserID == "" {
+		movement.UserID = actorID(tx.Statement.Context)
+	}
+
+	return tx.Create(movement).Error
+}
+
+// actorID returns the user making the current request, if any
+func actorID(ctx context.Context) string {
+	actor, _ := utils.ActorFromContext(ctx)
+	return actor.UserID
+}
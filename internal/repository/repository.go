@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/aryadhira/reseller-management/internal/models"
@@ -12,50 +13,106 @@ type Repository struct {
 	Product  ProductRepository
 	Order    OrderRepository
 	Payment  PaymentRepository
+	Credit   CreditRepository
+	Sequence SequenceRepository
+	Shipment ShipmentRepository
+	Bank     BankStatementRepository
+
+	db *gorm.DB
 }
 
 type ResellerRepository interface {
-	Create(reseller *models.Reseller) error
-	GetAll() ([]models.Reseller, error)
-	GetByID(id string) (*models.Reseller, error)
-	Update(id string, reseller *models.Reseller) error
-	Delete(id string) error
-	GetWithOrders(id string) (*models.Reseller, error)
+	Create(ctx context.Context, reseller *models.Reseller) error
+	GetAll(ctx context.Context) ([]models.Reseller, error)
+	GetByID(ctx context.Context, id string) (*models.Reseller, error)
+	Update(ctx context.Context, id string, reseller *models.Reseller) error
+	Delete(ctx context.Context, id string) error
+	GetWithOrders(ctx context.Context, id string) (*models.Reseller, error)
 }
 
 type ProductRepository interface {
-	Create(product *models.Product) error
-	GetAll() ([]models.Product, error)
-	GetByID(id string) (*models.Product, error)
-	Update(id string, product *models.Product) error
-	Delete(id string) error
-	Restock(id string, quantity int) error
-	GetLowStock() ([]models.Product, error)
+	Create(ctx context.Context, product *models.Product) error
+	GetAll(ctx context.Context) ([]models.Product, error)
+	GetByID(ctx context.Context, id string) (*models.Product, error)
+	Update(ctx context.Context, id string, product *models.Product) error
+	Delete(ctx context.Context, id string) error
+	MoveStock(ctx context.Context, movement *models.StockMovement) error
+	GetMovements(ctx context.Context, productID string) ([]models.StockMovement, error)
+	GetMovementTotal(ctx context.Context, productID string) (int, error)
+	GetLowStock(ctx context.Context) ([]models.Product, error)
 }
 
 type OrderRepository interface {
-	Create(order *models.Order) error
-	GetAll() ([]models.Order, error)
-	GetByID(id string) (*models.Order, error)
-	Update(id string, order *models.Order) error
-	Delete(id string) error
-	Cancel(id string) error
+	Create(ctx context.Context, order *models.Order) error
+	GetAll(ctx context.Context, search string) ([]models.Order, error)
+	GetByID(ctx context.Context, id string) (*models.Order, error)
+	GetForUpdate(ctx context.Context, id string) (*models.Order, error)
+	Update(ctx context.Context, id string, order *models.Order) error
+	CreateItem(ctx context.Context, item *models.OrderItem) error
+	UpdateItem(ctx context.Context, item *models.OrderItem) error
+	DeleteItem(ctx context.Context, itemID string) error
+	UpdateTotals(ctx context.Context, order *models.Order, paymentStatus string) error
+	SetInvoiceNumber(ctx context.Context, id string, invoiceNumber string, invoicedAt time.Time) error
+	UpdateItemReturned(ctx context.Context, item *models.OrderItem) error
+	CreateReturn(ctx context.Context, orderReturn *models.OrderReturn) error
+	GetReturns(ctx context.Context, orderID string) ([]models.OrderReturn, error)
+	Delete(ctx context.Context, id string) error
+	Cancel(ctx context.Context, id string) error
+	UpdateStatus(ctx context.Context, id string, from, to models.OrderStatus, notes string) error
+	GetStatusHistory(ctx context.Context, id string) ([]models.OrderStatusHistory, error)
 }
 
 type PaymentRepository interface {
-	GetAll() ([]models.Payment, error)
-	GetByOrderID(orderID string) (*models.Payment, error)
-	Create(payment *models.Payment) error
-	Update(payment *models.Payment) error
-	GetAllTransactions() ([]models.Transaction, error)
-	CreateTransaction(transaction *models.Transaction) error
-	UpdateBalance(initialBalance float64) error
-	GetBalance() (*models.Balance, error)
-	GetDashboardData() (*DashboardData, error)
-	GetRecentTransactions(limit int) ([]models.Transaction, error)
-	GetCashInByDateRange(start, end time.Time) (float64, error)
-	GetCashOutByDateRange(start, end time.Time) (float64, error)
-	GetUnpaidOrders() ([]models.Order, error)
+	GetAll(ctx context.Context) ([]models.Payment, error)
+	GetByOrderID(ctx context.Context, orderID string) (*models.Payment, error)
+	GetByOrderIDs(ctx context.Context, orderIDs []string) ([]models.Payment, error)
+	Create(ctx context.Context, payment *models.Payment) error
+	Update(ctx context.Context, payment *models.Payment) error
+	CancelByOrderID(ctx context.Context, orderID string) error
+	CreateReceipt(ctx context.Context, receipt *models.PaymentReceipt) error
+	GetReceiptForUpdate(ctx context.Context, id string) (*models.PaymentReceipt, error)
+	VoidReceipt(ctx context.Context, receipt *models.PaymentReceipt) error
+	RefreshAmountPaid(ctx context.Context, orderID string) (float64, error)
+	GetAllTransactions(ctx context.Context) ([]models.Transaction, error)
+	CreateTransaction(ctx context.Context, transaction *models.Transaction) error
+	UpdateBalance(ctx context.Context, initialBalance float64) error
+	AdjustInitialBalance(ctx context.Context, adjustment *models.BalanceAdjustment) error
+	GetBalanceAdjustments(ctx context.Context) ([]models.BalanceAdjustment, error)
+	GetBalance(ctx context.Context) (*models.Balance, error)
+	GetDashboardData(ctx context.Context) (*DashboardData, error)
+	GetRecentTransactions(ctx context.Context, limit int) ([]models.Transaction, error)
+	GetCashInByDateRange(ctx context.Context, start, end time.Time) (float64, error)
+	GetCashOutByDateRange(ctx context.Context, start, end time.Time) (float64, error)
+	GetUnpaidOrders(ctx context.Context) ([]models.Order, error)
+	GetUnpaidOrdersByReseller(ctx context.Context, resellerID string) ([]models.Order, error)
+	MarkOverdue(ctx context.Context, now time.Time) (int64, error)
+}
+
+type CreditRepository interface {
+	Create(ctx context.Context, entry *models.ResellerCreditEntry) error
+	GetBalance(ctx context.Context, resellerID string) (float64, error)
+	GetBalanceForUpdate(ctx context.Context, resellerID string) (float64, error)
+	GetEntries(ctx context.Context, resellerID string) ([]models.ResellerCreditEntry, error)
+}
+
+type SequenceRepository interface {
+	Next(ctx context.Context, name string, year int) (int64, error)
+}
+
+type ShipmentRepository interface {
+	Create(ctx context.Context, shipment *models.Shipment) error
+	Update(ctx context.Context, shipment *models.Shipment) error
+	GetForUpdate(ctx context.Context, orderID string, id string) (*models.Shipment, error)
+	GetByOrderID(ctx context.Context, orderID string) ([]models.Shipment, error)
+}
+
+type BankStatementRepository interface {
+	Create(ctx context.Context, statement *models.BankStatement) error
+	GetAll(ctx context.Context) ([]models.BankStatement, error)
+	GetByID(ctx context.Context, id string) (*models.BankStatement, error)
+	GetImportedFingerprints(ctx context.Context, fingerprints []string) (map[string]bool, error)
+	GetLineForUpdate(ctx context.Context, statementID string, id string) (*models.BankStatementLine, error)
+	DecideLine(ctx context.Context, line *models.BankStatementLine) error
 }
 
 func NewRepository(db *gorm.DB) *Repository {
@@ -64,5 +121,19 @@ func NewRepository(db *gorm.DB) *Repository {
 		Product:  NewProductRepository(db),
 		Order:    NewOrderRepository(db),
 		Payment:  NewPaymentRepository(db),
+		Credit:   NewCreditRepository(db),
+		Sequence: NewSequenceRepository(db),
+		Shipment: NewShipmentRepository(db),
+		Bank:     NewBankStatementRepository(db),
+		db:       db,
 	}
+}
+
+// Transaction runs fn with repositories bound to a single database
+// transaction. The transaction is committed when fn returns nil and rolled
+// back when it returns an error or panics.
+func (r *Repository) Transaction(ctx context.Context, fn func(tx *Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepository(tx))
+	})
 }
\ No newline at end of file
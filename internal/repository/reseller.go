@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/aryadhira/reseller-management/internal/models"
 	"gorm.io/gorm"
 )
@@ -13,32 +15,32 @@ func NewResellerRepository(db *gorm.DB) *resellerRepository {
 	return &resellerRepository{db: db}
 }
 
-func (r *resellerRepository) Create(reseller *models.Reseller) error {
-	return r.db.Create(reseller).Error
+func (r *resellerRepository) Create(ctx context.Context, reseller *models.Reseller) error {
+	return r.db.WithContext(ctx).Create(reseller).Error
 }
 
-func (r *resellerRepository) GetAll() ([]models.Reseller, error) {
+func (r *resellerRepository) GetAll(ctx context.Context) ([]models.Reseller, error) {
 	var resellers []models.Reseller
-	err := r.db.Find(&resellers).Error
+	err := r.db.WithContext(ctx).Find(&resellers).Error
 	return resellers, err
 }
 
-func (r *resellerRepository) GetByID(id string) (*models.Reseller, error) {
+func (r *resellerRepository) GetByID(ctx context.Context, id string) (*models.Reseller, error) {
 	var reseller models.Reseller
-	err := r.db.Where("id = ?", id).First(&reseller).Error
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&reseller).Error
 	return &reseller, err
 }
 
-func (r *resellerRepository) Update(id string, reseller *models.Reseller) error {
-	return r.db.Model(&models.Reseller{}).Where("id = ?", id).Updates(reseller).Error
+func (r *resellerRepository) Update(ctx context.Context, id string, reseller *models.Reseller) error {
+	return r.db.WithContext(ctx).Model(&models.Reseller{}).Where("id = ?", id).Updates(reseller).Error
 }
 
-func (r *resellerRepository) Delete(id string) error {
-	return r.db.Delete(&models.Reseller{}, "id = ?", id).Error
+func (r *resellerRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&models.Reseller{}, "id = ?", id).Error
 }
 
-func (r *resellerRepository) GetWithOrders(id string) (*models.Reseller, error) {
+func (r *resellerRepository) GetWithOrders(ctx context.Context, id string) (*models.Reseller, error) {
 	var reseller models.Reseller
-	err := r.db.Preload("Orders").Preload("Orders.OrderItems").Preload("Orders.Payment").Where("id = ?", id).First(&reseller).Error
+	err := r.db.WithContext(ctx).Preload("Orders").Preload("Orders.OrderItems").Preload("Orders.Payment").Where("id = ?", id).First(&reseller).Error
 	return &reseller, err
 }
\ No newline at end of file
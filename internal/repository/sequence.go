@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type sequenceRepository struct {
+	db *gorm.DB
+}
+
+func NewSequenceRepository(db *gorm.DB) *sequenceRepository {
+	return &sequenceRepository{db: db}
+}
+
+// Next returns the next value of the named sequence for the given year. The
+// sequence row stays locked until the surrounding transaction ends, so values
+// are handed out in order and a rolled back transaction leaves no gap.
+func (r *sequenceRepository) Next(ctx context.Context, name string, year int) (int64, error) {
+	var value int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tenantID, _ := utils.TenantIDFromContext(ctx)
+		sequence := models.Sequence{TenantID: tenantID, Name: name, Year: year}
+
+		// Start the sequence if this is its first use of the year
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "name"}, {Name: "year"}},
+			DoNothing: true,
+		}).Create(&sequence).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name = ? AND year = ?", name, year).
+			First(&sequence).Error
+		if err != nil {
+			return err
+		}
+
+		sequence.Value++
+		if err := tx.Model(&sequence).Update("value", sequence.Value).Error; err != nil {
+			return err
+		}
+
+		value = sequence.Value
+		return nil
+	})
+	return value, err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"gorm.io/gorm"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) interfaces.SessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+func (s *sessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return s.db.WithContext(ctx).Create(session).Error
+}
+
+func (s *sessionRepository) FindByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	var session models.Session
+	err := s.db.WithContext(ctx).Where("refresh_token_hash = ?", hash).First(&session).Error
+	return &session, err
+}
+
+func (s *sessionRepository) FindByAccessTokenID(ctx context.Context, accessTokenID string) (*models.Session, error) {
+	var session models.Session
+	err := s.db.WithContext(ctx).Where("access_token_id = ?", accessTokenID).First(&session).Error
+	return &session, err
+}
+
+// Rotate revokes the old session and stores its replacement atomically. It
+// returns utils.ErrSessionRevoked if the old session was already revoked,
+// which happens when the same refresh token is used twice.
+func (s *sessionRepository) Rotate(ctx context.Context, oldID string, next *models.Session) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", oldID).
+			Updates(map[string]interface{}{
+				"revoked_at":     time.Now(),
+				"replaced_by_id": next.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.ErrSessionRevoked
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+func (s *sessionRepository) Revoke(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (s *sessionRepository) RevokeAllForUser(ctx context.Context, userID string) error {
+	return s.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
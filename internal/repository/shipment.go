@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type shipmentRepository struct {
+	db *gorm.DB
+}
+
+func NewShipmentRepository(db *gorm.DB) *shipmentRepository {
+	return &shipmentRepository{db: db}
+}
+
+func (r *shipmentRepository) Create(ctx context.Context, shipment *models.Shipment) error {
+	return r.db.WithContext(ctx).Create(shipment).Error
+}
+
+func (r *shipmentRepository) Update(ctx context.Context, shipment *models.Shipment) error {
+	return r.db.WithContext(ctx).Save(shipment).Error
+}
+
+// GetForUpdate loads a shipment of an order and locks it until the
+// surrounding transaction ends
+func (r *shipmentRepository) GetForUpdate(ctx context.Context, orderID string, id string) (*models.Shipment, error) {
+	var shipment models.Shipment
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND order_id = ?", id, orderID).First(&shipment).Error
+	return &shipment, err
+}
+
+func (r *shipmentRepository) GetByOrderID(ctx context.Context, orderID string) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at ASC").Find(&shipments).Error
+	return shipments, err
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aryadhira/reseller-management/internal/interfaces"
 	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"gorm.io/gorm"
 )
 
@@ -22,14 +23,38 @@ func (u *userRepository) Create(ctx context.Context, user *models.User) error {
 	return u.db.WithContext(ctx).Create(user).Error
 }
 
+// CreateOwner creates a new tenant together with its owner account
+func (u *userRepository) CreateOwner(ctx context.Context, tenant *models.Tenant, owner *models.User) error {
+	return u.db.WithContext(utils.WithTenantID(ctx, tenant.ID)).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+
+		owner.TenantID = tenant.ID
+		return tx.Create(owner).Error
+	})
+}
+
 func (u *userRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
 	var user models.User
 	err := u.db.WithContext(ctx).Where("id = ?", id).First(&user).Error
 	return &user, err
 }
 
+// FindByEmail looks in every tenant, emails are unique across tenants and
+// identify the user at login
 func (u *userRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := u.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	err := u.db.WithContext(utils.WithAllTenants(ctx)).Where("email = ?", email).First(&user).Error
 	return &user, err
 }
+
+func (u *userRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := u.db.WithContext(ctx).Order("created_at ASC").Find(&users).Error
+	return users, err
+}
+
+func (u *userRepository) UpdateRole(ctx context.Context, id string, role models.Role) error {
+	return u.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("role", role).Error
+}
@@ -1,73 +1,192 @@
 package routes
 
 import (
+	"github.com/aryadhira/reseller-management/internal/config"
 	"github.com/aryadhira/reseller-management/internal/handlers"
+	"github.com/aryadhira/reseller-management/internal/middleware"
+	"github.com/aryadhira/reseller-management/internal/models"
 	"github.com/aryadhira/reseller-management/internal/services"
 	"github.com/aryadhira/reseller-management/internal/repository"
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
-func SetupRoutes(app *fiber.App, db *gorm.DB) {
+func SetupRoutes(app *fiber.App, db *gorm.DB, cfg *config.Config) {
 	// Initialize repository
 	repo := repository.NewRepository(db)
-	
+	userRepo := repository.NewUserRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	auditLogRepo := repository.NewAuditLogRepository(db)
+	idempotencyKeyRepo := repository.NewIdempotencyKeyRepository(db)
+
 	// Initialize services
-	serviceInstance := services.NewService(repo)
-	
+	serviceInstance := services.NewService(repo, cfg)
+	authService := services.NewAuthService(userRepo, sessionRepo, cfg.RefreshExpired)
+	userService := services.NewUserService(userRepo, repo.Reseller)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	auditService := services.NewAuditService(auditLogRepo)
+
 	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(authService, cfg.JWTSecret, cfg.JWTExpired)
+	userHandler := handlers.NewUserHandler(userService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	auditHandler := handlers.NewAuditHandler(auditService)
 	resellerHandler := handlers.NewResellerHandler(serviceInstance.Reseller)
 	productHandler := handlers.NewProductHandler(serviceInstance.Product)
 	orderHandler := handlers.NewOrderHandler(serviceInstance.Order)
 	paymentHandler := handlers.NewPaymentHandler(serviceInstance.Payment)
-	
+	portalHandler := handlers.NewPortalHandler(serviceInstance.Portal)
+	creditHandler := handlers.NewCreditHandler(serviceInstance.Credit)
+	bankStatementHandler := handlers.NewBankStatementHandler(serviceInstance.Bank)
+
+	// Initialize middleware
+	auth := middleware.NewAuthMiddleware(cfg.JWTSecret, userRepo, sessionRepo, apiKeyRepo)
+	can := auth.RequirePermission
+	canWhen := auth.RequirePermissionWhen
+	// Mounted on each mutating route after its permission checks, so rejected
+	// requests are not stored and replayed
+	idempotent := middleware.NewIdempotencyMiddleware(idempotencyKeyRepo, cfg.IdempotencyTTL).Handle()
+
 	// API routes
 	api := app.Group("/api/v1")
-	
+
+	// Auth routes (public)
+	authRoutes := api.Group("/auth")
+	authRoutes.Post("/register", authHandler.Register)
+	authRoutes.Post("/login", authHandler.Login)
+	authRoutes.Post("/refresh", authHandler.Refresh)
+	authRoutes.Post("/logout", auth.Protected(), authHandler.Logout)
+	authRoutes.Post("/logout-all", auth.Protected(), authHandler.LogoutAll)
+
+	// Current user profile
+	api.Get("/me", auth.Protected(), userHandler.GetProfile)
+
+	// User and role administration
+	admin := api.Group("/admin", auth.Protected(), can(models.PermUsersManage))
+	admin.Get("/users", userHandler.ListUsers)
+	admin.Post("/users", userHandler.CreateUser)
+	admin.Put("/users/:id/role", userHandler.UpdateUserRole)
+
+	// API keys for machine-to-machine integrations
+	apiKeys := api.Group("/api-keys", auth.Protected(), can(models.PermAPIKeysManage))
+	apiKeys.Post("/", apiKeyHandler.CreateAPIKey)
+	apiKeys.Get("/", apiKeyHandler.ListAPIKeys)
+	apiKeys.Delete("/:id", apiKeyHandler.RevokeAPIKey)
+
+	// Audit log, read-only: entries are written automatically on every change
+	api.Get("/audit", auth.Protected(), can(models.PermAuditRead), auditHandler.ListAuditLogs)
+
 	// Reseller routes
-	resellers := api.Group("/resellers")
-	resellers.Post("/", resellerHandler.CreateReseller)
-	resellers.Get("/", resellerHandler.GetAllResellers)
-	resellers.Get("/:id", resellerHandler.GetResellerByID)
-	resellers.Get("/:id/profile", resellerHandler.GetResellerWithOrders) // Detailed profile with order history
-	resellers.Put("/:id", resellerHandler.UpdateReseller)
-	resellers.Delete("/:id", resellerHandler.DeleteReseller)
-	
+	resellers := api.Group("/resellers", auth.Protected())
+	resellers.Post("/", can(models.PermResellersWrite), idempotent, resellerHandler.CreateReseller)
+	resellers.Get("/", can(models.PermResellersRead), resellerHandler.GetAllResellers)
+	resellers.Get("/:id", can(models.PermResellersRead), resellerHandler.GetResellerByID)
+	resellers.Get("/:id/profile", can(models.PermResellersRead), resellerHandler.GetResellerWithOrders) // Detailed profile with order history
+	resellers.Put("/:id", can(models.PermResellersWrite), idempotent, resellerHandler.UpdateReseller)
+	resellers.Delete("/:id", can(models.PermResellersDelete), idempotent, resellerHandler.DeleteReseller)
+	resellers.Get("/:id/credit", can(models.PermPaymentsRead), creditHandler.GetCreditBalance)
+	resellers.Get("/:id/credit/statement", can(models.PermPaymentsRead), creditHandler.GetCreditStatement)
+	resellers.Post("/:id/credit/deposits", can(models.PermPaymentsWrite), idempotent, creditHandler.RecordDeposit)
+
 	// Product routes
-	products := api.Group("/products")
-	products.Post("/", productHandler.CreateProduct)
-	products.Get("/", productHandler.GetAllProducts)
-	products.Get("/:id", productHandler.GetProductByID)
-	products.Put("/:id", productHandler.UpdateProduct)
-	products.Delete("/:id", productHandler.DeleteProduct)
-	products.Post("/:id/restock", productHandler.RestockProduct)
-	products.Get("/low-stock", productHandler.GetLowStockProducts)
-	
+	products := api.Group("/products", auth.Protected())
+	products.Post("/", can(models.PermProductsWrite), idempotent, productHandler.CreateProduct)
+	products.Get("/", can(models.PermProductsRead), productHandler.GetAllProducts)
+	products.Get("/:id", can(models.PermProductsRead), productHandler.GetProductByID)
+	products.Put("/:id", can(models.PermProductsWrite), idempotent, productHandler.UpdateProduct)
+	products.Delete("/:id", can(models.PermProductsWrite), idempotent, productHandler.DeleteProduct)
+	products.Post("/:id/restock", can(models.PermProductsWrite), idempotent, productHandler.RestockProduct)
+	products.Post("/:id/adjust-stock", can(models.PermProductsWrite), idempotent, productHandler.AdjustStock)
+	products.Get("/:id/movements", can(models.PermProductsRead), productHandler.GetStockMovements)
+	products.Get("/:id/stock-check", can(models.PermProductsRead), productHandler.CheckStock)
+	products.Get("/low-stock", can(models.PermProductsRead), productHandler.GetLowStockProducts)
+
 	// Order routes
-	orders := api.Group("/orders")
-	orders.Post("/", orderHandler.CreateOrder)
-	orders.Get("/", orderHandler.GetAllOrders)
-	orders.Get("/:id", orderHandler.GetOrderByID)
-	orders.Put("/:id", orderHandler.UpdateOrder)
-	orders.Delete("/:id", orderHandler.DeleteOrder)
-	orders.Patch("/:id/cancel", orderHandler.CancelOrder)
-	
+	orders := api.Group("/orders", auth.Protected())
+	orders.Post("/", can(models.PermOrdersWrite), idempotent, orderHandler.CreateOrder)
+	orders.Get("/", can(models.PermOrdersRead), orderHandler.GetAllOrders)
+	orders.Get("/:id", can(models.PermOrdersRead), orderHandler.GetOrderByID)
+	orders.Put("/:id", can(models.PermOrdersWrite), idempotent, orderHandler.UpdateOrder)
+	orders.Delete("/:id", can(models.PermOrdersWrite), idempotent, orderHandler.DeleteOrder)
+	orders.Patch("/:id/cancel", can(models.PermOrdersWrite), canWhen(refundsCancellation, models.PermCashOut), idempotent, orderHandler.CancelOrder)
+	orders.Post("/:id/items", can(models.PermOrdersWrite), idempotent, orderHandler.AddOrderItem)
+	orders.Put("/:id/items/:itemID", can(models.PermOrdersWrite), idempotent, orderHandler.UpdateOrderItem)
+	orders.Delete("/:id/items/:itemID", can(models.PermOrdersWrite), idempotent, orderHandler.RemoveOrderItem)
+	orders.Post("/:id/returns", can(models.PermOrdersWrite), canWhen(refundsReturn, models.PermCashOut), idempotent, orderHandler.CreateReturn)
+	orders.Get("/:id/returns", can(models.PermOrdersRead), orderHandler.GetReturns)
+	orders.Patch("/:id/status", can(models.PermOrdersWrite), canWhen(cancelsOrder, models.PermCashOut), idempotent, orderHandler.UpdateOrderStatus)
+	orders.Get("/:id/timeline", can(models.PermOrdersRead), orderHandler.GetOrderTimeline)
+	orders.Get("/:id/invoice.pdf", can(models.PermOrdersRead), orderHandler.GetInvoicePDF)
+	orders.Post("/:id/shipments", can(models.PermOrdersWrite), canWhen(recordsExpense, models.PermCashOut), idempotent, orderHandler.CreateShipment)
+	orders.Get("/:id/shipments", can(models.PermOrdersRead), orderHandler.GetShipments)
+	orders.Put("/:id/shipments/:shipmentID", can(models.PermOrdersWrite), canWhen(recordsExpense, models.PermCashOut), idempotent, orderHandler.UpdateShipment)
+
 	// Payment and financial routes
-	payments := api.Group("/payments")
-	payments.Get("/", paymentHandler.GetAllPayments)
-	payments.Get("/order/:orderID", paymentHandler.GetPaymentByOrderID)
-	payments.Post("/order/:orderID/pay", paymentHandler.RecordPayment)
-	
-	transactions := api.Group("/transactions")
-	transactions.Get("/", paymentHandler.GetAllTransactions)
-	transactions.Post("/cash-in", paymentHandler.RecordCashIn)
-	transactions.Post("/cash-out", paymentHandler.RecordCashOut)
-	
+	payments := api.Group("/payments", auth.Protected())
+	payments.Get("/", can(models.PermPaymentsRead), paymentHandler.GetAllPayments)
+	payments.Get("/order/:orderID", can(models.PermPaymentsRead), paymentHandler.GetPaymentByOrderID)
+	payments.Post("/order/:orderID/pay", can(models.PermPaymentsWrite), idempotent, paymentHandler.RecordPayment)
+	payments.Post("/receipts/:receiptID/void", can(models.PermPaymentsWrite), idempotent, paymentHandler.VoidPayment)
+	payments.Post("/allocate", can(models.PermPaymentsWrite), idempotent, paymentHandler.AllocatePayment)
+
+	// Bank statement import and reconciliation
+	bankStatements := api.Group("/bank-statements", auth.Protected())
+	bankStatements.Get("/parsers", can(models.PermPaymentsRead), bankStatementHandler.GetParsers)
+	bankStatements.Post("/", can(models.PermPaymentsWrite), idempotent, bankStatementHandler.ImportStatement)
+	bankStatements.Get("/", can(models.PermPaymentsRead), bankStatementHandler.GetAllStatements)
+	bankStatements.Get("/:id", can(models.PermPaymentsRead), bankStatementHandler.GetStatement)
+	bankStatements.Post("/:id/matches", can(models.PermPaymentsWrite), idempotent, bankStatementHandler.DecideMatches)
+
+	transactions := api.Group("/transactions", auth.Protected())
+	transactions.Get("/", can(models.PermTransactionsRead), paymentHandler.GetAllTransactions)
+	transactions.Post("/cash-in", can(models.PermCashIn), idempotent, paymentHandler.RecordCashIn)
+	transactions.Post("/cash-out", can(models.PermCashOut), idempotent, paymentHandler.RecordCashOut)
+
 	// Balance routes
-	balance := api.Group("/balance")
-	balance.Put("/", paymentHandler.UpdateBalance)
-	balance.Get("/", paymentHandler.GetBalance)
-	
+	balance := api.Group("/balance", auth.Protected())
+	balance.Put("/", can(models.PermBalanceWrite), idempotent, paymentHandler.UpdateBalance)
+	balance.Get("/", can(models.PermBalanceRead), paymentHandler.GetBalance)
+	balance.Get("/history", can(models.PermBalanceRead), paymentHandler.GetBalanceHistory)
+
+	// Reseller self-service portal, always scoped to the reseller in the token
+	portal := api.Group("/portal", auth.Protected(), can(models.PermPortalRead))
+	portal.Get("/me", portalHandler.GetProfile)
+	portal.Get("/orders", portalHandler.GetOrders)
+	portal.Get("/orders/:id", portalHandler.GetOrder)
+	portal.Get("/payments", portalHandler.GetPayments)
+	portal.Get("/balance", portalHandler.GetBalance)
+
 	// Dashboard route
-	api.Get("/dashboard", paymentHandler.GetDashboardData)
-}
\ No newline at end of file
+	api.Get("/dashboard", auth.Protected(), can(models.PermDashboardRead), paymentHandler.GetDashboardData)
+}
+
+// refundsCancellation reports whether money paid for a cancelled order is to
+// be refunded in cash, which is the default
+func refundsCancellation(c *fiber.Ctx) bool {
+	return models.ReturnSettlement(c.Query("settlement", string(models.SettleRefund))) == models.SettleRefund
+}
+
+// cancelsOrder reports whether a status change cancels the order, refunding
+// what was paid for it in cash
+func cancelsOrder(c *fiber.Ctx) bool {
+	var req struct {
+		Status models.OrderStatus `json:"status"`
+	}
+	return c.BodyParser(&req) == nil && req.Status == models.OrderCancelled
+}
+
+// refundsReturn reports whether a return is to be refunded in cash
+func refundsReturn(c *fiber.Ctx) bool {
+	var req models.CreateReturnRequest
+	return c.BodyParser(&req) == nil && req.Settlement == models.SettleRefund
+}
+
+// recordsExpense reports whether a shipment change records its shipping cost
+// as a cash-out expense
+func recordsExpense(c *fiber.Ctx) bool {
+	var req struct {
+		RecordExpense bool `json:"record_expense"`
+	}
+	return c.BodyParser(&req) == nil && req.RecordExpense
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+type apiKeyService struct {
+	apiKeyRepo interfaces.APIKeyRepository
+}
+
+func NewAPIKeyService(apiKeyRepo interfaces.APIKeyRepository) interfaces.APIKeyService {
+	return &apiKeyService{apiKeyRepo: apiKeyRepo}
+}
+
+func (a *apiKeyService) CreateAPIKey(ctx context.Context, userID string, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	for _, scope := range req.Scopes {
+		if !scope.IsValidScope() {
+			return nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	plainKey, err := utils.GenerateAPIKey()
+	if err != nil {
+		return nil, errors.New("failed to generate api key")
+	}
+
+	key := &models.APIKey{
+		ID:        uuid.NewString(),
+		Name:      req.Name,
+		Prefix:    plainKey[:10],
+		KeyHash:   utils.HashToken(plainKey),
+		Scopes:    req.Scopes,
+		CreatedBy: userID,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err := a.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, errors.New("failed to create api key")
+	}
+
+	return &models.CreateAPIKeyResponse{
+		APIKey: key,
+		Key:    plainKey,
+	}, nil
+}
+
+func (a *apiKeyService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return a.apiKeyRepo.FindAll(ctx)
+}
+
+func (a *apiKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	if _, err := a.apiKeyRepo.FindByID(ctx, id); err != nil {
+		return errors.New("api key not found")
+	}
+
+	return a.apiKeyRepo.Revoke(ctx, id)
+}
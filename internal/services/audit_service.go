@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+type auditService struct {
+	auditLogRepo interfaces.AuditLogRepository
+}
+
+func NewAuditService(auditLogRepo interfaces.AuditLogRepository) interfaces.AuditService {
+	return &auditService{auditLogRepo: auditLogRepo}
+}
+
+func (a *auditService) ListAuditLogs(ctx context.Context, filter *models.AuditLogFilter) ([]models.AuditLog, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return a.auditLogRepo.FindAll(ctx, filter)
+}
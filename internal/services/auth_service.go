@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/aryadhira/reseller-management/internal/interfaces"
@@ -12,11 +13,17 @@ import (
 )
 
 type authService struct {
-	userRepo interfaces.UserRepository
+	userRepo          interfaces.UserRepository
+	sessionRepo       interfaces.SessionRepository
+	refreshExpiration time.Duration
 }
 
-func NewAuthService(userRepo interfaces.UserRepository) interfaces.AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(userRepo interfaces.UserRepository, sessionRepo interfaces.SessionRepository, refreshExpiration time.Duration) interfaces.AuthService {
+	return &authService{
+		userRepo:          userRepo,
+		sessionRepo:       sessionRepo,
+		refreshExpiration: refreshExpiration,
+	}
 }
 
 func (a *authService) Register(ctx context.Context, req *models.RegisterRequest) (*models.AuthResponse, error) {
@@ -32,15 +39,29 @@ func (a *authService) Register(ctx context.Context, req *models.RegisterRequest)
 		return nil, errors.New("failed to hash password")
 	}
 
+	// Every registration starts a new business (tenant) owned by the user.
+	// Further staff accounts are added by the owner through the admin API.
+	businessName := req.BusinessName
+	if businessName == "" {
+		businessName = req.Name
+	}
+
+	tenant := &models.Tenant{
+		ID:   uuid.NewString(),
+		Name: businessName,
+	}
+
 	// Create user
 	user := &models.User{
 		ID:       uuid.NewString(),
+		TenantID: tenant.ID,
 		Name:     req.Name,
 		Email:    req.Email,
 		Password: hashedPassword,
+		Role:     models.RoleOwner,
 	}
 
-	if err := a.userRepo.Create(ctx, user); err != nil {
+	if err := a.userRepo.CreateOwner(ctx, tenant, user); err != nil {
 		return nil, errors.New("failed to create user")
 	}
 
@@ -58,14 +79,110 @@ func (a *authService) Login(ctx context.Context, req *models.LoginRequest, jwtSe
 		return nil, errors.New("invalid credentials")
 	}
 
+	session, response, err := a.issueTokens(user, jwtSecret, expiresIn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.sessionRepo.Create(ctx, session); err != nil {
+		return nil, errors.New("failed to create session")
+	}
+
+	return response, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// refresh token is revoked; presenting an already rotated token revokes
+// every session of the user, since it indicates the token was stolen.
+func (a *authService) Refresh(ctx context.Context, refreshToken string, jwtSecret string, expiresIn time.Duration) (*models.AuthResponse, error) {
+	current, err := a.sessionRepo.FindByRefreshTokenHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if current.RevokedAt != nil {
+		if current.ReplacedByID != nil {
+			return nil, a.revokeReusedSessions(ctx, current.UserID)
+		}
+		return nil, errors.New("refresh token has been revoked")
+	}
+
+	if !current.IsActive(time.Now()) {
+		return nil, errors.New("refresh token has expired")
+	}
+
+	// Sessions carry no tenant, the user's tenant is only known once found
+	user, err := a.userRepo.FindByID(utils.WithAllTenants(ctx), current.UserID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	next, response, err := a.issueTokens(user, jwtSecret, expiresIn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.sessionRepo.Rotate(ctx, current.ID, next); err != nil {
+		if errors.Is(err, utils.ErrSessionRevoked) {
+			return nil, a.revokeReusedSessions(ctx, current.UserID)
+		}
+		return nil, errors.New("failed to refresh session")
+	}
+
+	return response, nil
+}
+
+// revokeReusedSessions revokes every session of a user whose rotated refresh
+// token was presented again. The user stays exposed when that fails, so the
+// failure is returned instead of the usual revoked token error.
+func (a *authService) revokeReusedSessions(ctx context.Context, userID string) error {
+	if err := a.sessionRepo.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions after refresh token reuse: %w", err)
+	}
+	return errors.New("refresh token has been revoked")
+}
+
+func (a *authService) Logout(ctx context.Context, accessTokenID string) error {
+	session, err := a.sessionRepo.FindByAccessTokenID(ctx, accessTokenID)
+	if err != nil {
+		return errors.New("session not found")
+	}
+
+	return a.sessionRepo.Revoke(ctx, session.ID)
+}
+
+func (a *authService) LogoutAll(ctx context.Context, userID string) error {
+	return a.sessionRepo.RevokeAllForUser(ctx, userID)
+}
+
+// issueTokens generates a new access and refresh token pair along with the
+// session that tracks them. The session is not persisted.
+func (a *authService) issueTokens(user *models.User, jwtSecret string, expiresIn time.Duration) (*models.Session, *models.AuthResponse, error) {
+	accessTokenID := uuid.NewString()
+
 	// Generate JWT token
-	token, err := utils.GenerateToken(user, jwtSecret, expiresIn)
+	token, err := utils.GenerateToken(user, accessTokenID, jwtSecret, expiresIn)
 	if err != nil {
-		return nil, errors.New("failed to generate token")
+		return nil, nil, errors.New("failed to generate token")
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, nil, errors.New("failed to generate token")
+	}
+
+	session := &models.Session{
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		AccessTokenID:    accessTokenID,
+		ExpiresAt:        time.Now().Add(a.refreshExpiration),
 	}
 
-	return &models.AuthResponse{
-		User:        user,
-		AccessToken: token,
+	return session, &models.AuthResponse{
+		User:         user,
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(expiresIn.Seconds()),
 	}, nil
 }
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/bankstatement"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+type bankStatementService struct {
+	repo *repository.Repository
+}
+
+func NewBankStatementService(repo *repository.Repository) *bankStatementService {
+	return &bankStatementService{repo: repo}
+}
+
+func (s *bankStatementService) GetParsers() []string {
+	return bankstatement.Names()
+}
+
+// ImportStatement reads a bank statement with the parser registered for the
+// bank and stores its incoming transfers. Every transfer is matched against
+// the open orders and the best match is proposed for confirmation. An order is
+// proposed for at most one transfer of the statement. Transfers imported with
+// an earlier statement are skipped, so they cannot be confirmed twice.
+func (s *bankStatementService) ImportStatement(ctx context.Context, bank string, fileName string, file io.Reader) (*models.BankStatement, error) {
+	parser, ok := bankstatement.Lookup(bank)
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of %s", utils.ErrUnknownBankParser, bank, strings.Join(bankstatement.Names(), ", "))
+	}
+
+	entries, err := parser.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidBankStatement, err)
+	}
+
+	orders, err := s.repo.Payment.GetUnpaidOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]bankstatement.Candidate, 0, len(orders))
+	for _, order := range orders {
+		candidate := bankstatement.Candidate{
+			OrderID:      order.ID,
+			OrderNumber:  order.OrderNumber,
+			ResellerName: order.Reseller.Name,
+			Remaining:    order.TotalAmount,
+		}
+		if order.InvoiceNumber != nil {
+			candidate.InvoiceNumber = *order.InvoiceNumber
+		}
+		if order.Payment != nil {
+			candidate.Remaining = order.Payment.TotalAmount - order.Payment.AmountPaid
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// Only money coming in can pay an order
+	incoming := make([]bankstatement.Entry, 0, len(entries))
+	fingerprints := make([]string, 0, len(entries))
+	occurrences := make(map[string]int)
+	for _, entry := range entries {
+		if entry.Amount <= 0 {
+			continue
+		}
+		first := bankstatement.Fingerprint(bank, entry, 1)
+		occurrences[first]++
+		incoming = append(incoming, entry)
+		fingerprints = append(fingerprints, bankstatement.Fingerprint(bank, entry, occurrences[first]))
+	}
+
+	imported, err := s.repo.Bank.GetImportedFingerprints(ctx, fingerprints)
+	if err != nil {
+		return nil, err
+	}
+	if len(incoming) > 0 && len(imported) == len(incoming) {
+		return nil, utils.ErrBankStatementImported
+	}
+
+	statement := &models.BankStatement{
+		BaseModel:      models.BaseModel{ID: uuid.NewString()},
+		Bank:           strings.ToLower(bank),
+		FileName:       fileName,
+		DuplicateLines: len(imported),
+	}
+	for i, entry := range incoming {
+		if imported[fingerprints[i]] {
+			continue
+		}
+
+		line := models.BankStatementLine{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			StatementID: statement.ID,
+			Date:        entry.Date,
+			Description: entry.Description,
+			Reference:   entry.Reference,
+			Amount:      entry.Amount,
+			Status:      models.BankLineUnmatched,
+			Fingerprint: fingerprints[i],
+		}
+		if match, ok := bankstatement.Propose(entry, candidates); ok {
+			line.Status = models.BankLineProposed
+			line.OrderID = &match.OrderID
+			line.MatchScore = match.Score
+			line.MatchReason = strings.Join(match.Reasons, ", ")
+			candidates = removeCandidate(candidates, match.OrderID)
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+
+	if err := s.repo.Bank.Create(ctx, statement); err != nil {
+		return nil, err
+	}
+
+	return statement, nil
+}
+
+func (s *bankStatementService) GetAllStatements(ctx context.Context) ([]models.BankStatement, error) {
+	return s.repo.Bank.GetAll(ctx)
+}
+
+func (s *bankStatementService) GetStatement(ctx context.Context, id string) (*models.BankStatement, error) {
+	statement, err := s.repo.Bank.GetByID(ctx, id)
+	if err != nil {
+		return nil, utils.ErrBankStatementNotFound
+	}
+	return statement, nil
+}
+
+// DecideMatches confirms or rejects the matches of statement lines. Each
+// decision is applied in its own transaction, so one failing line does not
+// hold back the others. A confirmed line is recorded as a bank transfer
+// payment of its order through the normal payment flow.
+func (s *bankStatementService) DecideMatches(ctx context.Context, statementID string, req *models.BankMatchRequest) ([]models.BankMatchResult, error) {
+	statement, err := s.repo.Bank.GetByID(ctx, statementID)
+	if err != nil {
+		return nil, utils.ErrBankStatementNotFound
+	}
+
+	results := make([]models.BankMatchResult, 0, len(req.Decisions))
+	for _, decision := range req.Decisions {
+		result := models.BankMatchResult{LineID: decision.LineID}
+
+		err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+			line, err := tx.Bank.GetLineForUpdate(ctx, statement.ID, decision.LineID)
+			if err != nil {
+				return utils.ErrBankLineNotFound
+			}
+			result.Status = line.Status
+			if line.Status.IsDecided() {
+				return fmt.Errorf("%w: %s", utils.ErrBankLineDecided, line.Status)
+			}
+
+			switch decision.Action {
+			case "confirm":
+				if err := confirmLine(ctx, tx, line, decision.OrderID); err != nil {
+					return err
+				}
+			case "reject":
+				line.Status = models.BankLineRejected
+			default:
+				return utils.ErrInvalidBankDecision
+			}
+
+			decidedAt := time.Now()
+			line.DecidedAt = &decidedAt
+			if err := tx.Bank.DecideLine(ctx, line); err != nil {
+				return err
+			}
+
+			result.Status = line.Status
+			result.ReceiptID = line.ReceiptID
+			return nil
+		})
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// confirmLine records a statement line as a payment of the proposed order,
+// or of orderID when the user picked another order
+func confirmLine(ctx context.Context, tx *repository.Repository, line *models.BankStatementLine, orderID string) error {
+	if orderID == "" && line.OrderID != nil {
+		orderID = *line.OrderID
+	}
+	if orderID == "" {
+		return utils.ErrBankLineUnmatched
+	}
+
+	receipt := &models.PaymentReceipt{
+		Amount:     line.Amount,
+		Method:     models.PaymentBankTransfer,
+		Reference:  line.Reference,
+		ReceivedAt: line.Date,
+		Notes:      line.Description,
+	}
+	if err := recordPayment(ctx, tx, orderID, receipt); err != nil {
+		return err
+	}
+
+	line.Status = models.BankLineConfirmed
+	line.OrderID = &receipt.OrderID
+	line.ReceiptID = &receipt.ID
+	return nil
+}
+
+// removeCandidate drops the candidate for an order that was already proposed
+func removeCandidate(candidates []bankstatement.Candidate, orderID string) []bankstatement.Candidate {
+	remaining := candidates[:0:0]
+	for _, candidate := range candidates {
+		if candidate.OrderID != orderID {
+			remaining = append(remaining, candidate)
+		}
+	}
+	return remaining
+}
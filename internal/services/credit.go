@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+type creditService struct {
+	repo *repository.Repository
+}
+
+func NewCreditService(repo *repository.Repository) *creditService {
+	return &creditService{repo: repo}
+}
+
+// RecordDeposit records money a reseller pays in advance. The money comes in
+// as a CASH_IN transaction and is added to the reseller's credit.
+func (s *creditService) RecordDeposit(ctx context.Context, resellerID string, req *models.CreditDepositRequest) (*models.ResellerCreditEntry, error) {
+	if req.Amount <= 0 {
+		return nil, utils.ErrInvalidAmount
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentCash
+	}
+	if !req.Method.IsValid() || req.Method == models.PaymentCredit {
+		return nil, utils.ErrInvalidPaymentMethod
+	}
+
+	reseller, err := s.repo.Reseller.GetByID(ctx, resellerID)
+	if err != nil {
+		return nil, utils.ErrResellerNotFound
+	}
+
+	description := fmt.Sprintf("Credit deposit by %s via %s", reseller.Name, req.Method)
+	if req.Reference != "" {
+		description += " " + req.Reference
+	}
+
+	var entry *models.ResellerCreditEntry
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		transaction, err := recordCreditCashIn(ctx, tx, reseller.ID, req.Amount, description)
+		if err != nil {
+			return err
+		}
+
+		entry = &models.ResellerCreditEntry{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			ResellerID:  reseller.ID,
+			Amount:      req.Amount,
+			Type:        models.CreditDeposit,
+			ReferenceID: &transaction.ID,
+			Notes:       req.Notes,
+		}
+		return tx.Credit.Create(ctx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (s *creditService) GetCreditBalance(ctx context.Context, resellerID string) (*models.CreditBalance, error) {
+	reseller, err := s.repo.Reseller.GetByID(ctx, resellerID)
+	if err != nil {
+		return nil, utils.ErrResellerNotFound
+	}
+
+	balance, err := s.repo.Credit.GetBalance(ctx, reseller.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreditBalance{ResellerID: reseller.ID, Balance: balance}, nil
+}
+
+func (s *creditService) GetCreditStatement(ctx context.Context, resellerID string) (*models.CreditStatement, error) {
+	reseller, err := s.repo.Reseller.GetByID(ctx, resellerID)
+	if err != nil {
+		return nil, utils.ErrResellerNotFound
+	}
+
+	entries, err := s.repo.Credit.GetEntries(ctx, reseller.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.CreditStatement{
+		CreditBalance: models.CreditBalance{ResellerID: reseller.ID},
+		Entries:       entries,
+	}
+	for i := range statement.Entries {
+		statement.Balance += statement.Entries[i].Amount
+		statement.Entries[i].Balance = statement.Balance
+	}
+
+	return statement, nil
+}
+
+// recordCreditCashIn records money a reseller leaves with us as credit as a
+// CASH_IN transaction and recalculates the balance
+func recordCreditCashIn(ctx context.Context, tx *repository.Repository, resellerID string, amount float64, description string) (*models.Transaction, error) {
+	transaction := &models.Transaction{
+		BaseModel:   models.BaseModel{ID: uuid.NewString()},
+		Type:        models.CashIn,
+		Category:    models.Deposit,
+		Amount:      amount,
+		Description: description,
+		Date:        time.Now(),
+		ReferenceID: &resellerID,
+	}
+	if err := tx.Payment.CreateTransaction(ctx, transaction); err != nil {
+		return nil, err
+	}
+
+	// Recalculate the balance with the cash-in
+	if _, err := tx.Payment.GetBalance(ctx); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
@@ -1,149 +1,567 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/aryadhira/reseller-management/internal/invoice"
 	"github.com/aryadhira/reseller-management/internal/models"
 	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"github.com/google/uuid"
 )
 
+const (
+	orderSequence   = "order"
+	invoiceSequence = "invoice"
+)
+
 type orderService struct {
-	repo *repository.Repository
+	repo    *repository.Repository
+	taxRate float64
 }
 
-func NewOrderService(repo *repository.Repository) *orderService {
-	return &orderService{repo: repo}
+func NewOrderService(repo *repository.Repository, taxRate float64) *orderService {
+	return &orderService{repo: repo, taxRate: taxRate}
 }
 
-func (s *orderService) CreateOrder(order *models.Order) (*models.Order, error) {
+func (s *orderService) CreateOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
 	order.BaseModel = models.BaseModel{ID: uuid.NewString()}
+	order.Status = models.OrderPending // Later statuses are only reached through UpdateOrderStatus
+	order.TaxRate = s.taxRate          // Later changes to the tax rate leave placed orders alone
+
+	if err := validateDiscount(order.DiscountType, order.DiscountValue); err != nil {
+		return nil, err
+	}
+	for _, item := range order.OrderItems {
+		if item.Quantity <= 0 {
+			return nil, errors.New("quantity must be greater than zero")
+		}
+		if err := validateDiscount(item.DiscountType, item.DiscountValue); err != nil {
+			return nil, err
+		}
+	}
+	if order.ShippingFee < 0 {
+		return nil, errors.New("shipping fee cannot be negative")
+	}
 
 	// Validate that the reseller exists
-	_, err := s.repo.Reseller.GetByID(order.ResellerID)
+	reseller, err := s.repo.Reseller.GetByID(ctx, order.ResellerID)
 	if err != nil {
 		return nil, errors.New("reseller not found")
 	}
 
-	// Validate products and check stock availability
-	totalAmount := 0.0
-	for i := range order.OrderItems {
-		// Clear any existing ID to prevent primary key conflicts
-		order.OrderItems[i].BaseModel = models.BaseModel{}
+	// The reseller's payment terms set the due date, unless one was given
+	if order.OrderDate.IsZero() {
+		order.OrderDate = time.Now()
+	}
+	if order.DueDate == nil && reseller.PaymentTermDays > 0 {
+		dueDate := order.OrderDate.AddDate(0, 0, reseller.PaymentTermDays)
+		order.DueDate = &dueDate
+	}
+
+	// Stock checks, stock deductions, the order and its payment are saved
+	// together or not at all
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		// Validate products and check stock availability
+		names := make(map[string]string, len(order.OrderItems))
+		for i := range order.OrderItems {
+			// Clear any existing ID to prevent primary key conflicts
+			order.OrderItems[i].BaseModel = models.BaseModel{}
+
+			product, err := tx.Product.GetByID(ctx, order.OrderItems[i].ProductID)
+			if err != nil {
+				return fmt.Errorf("product with ID %s not found", order.OrderItems[i].ProductID)
+			}
+
+			if product.CurrentStock < order.OrderItems[i].Quantity {
+				return fmt.Errorf("insufficient stock for product %s. Available: %d, Requested: %d",
+					product.Name, product.CurrentStock, order.OrderItems[i].Quantity)
+			}
 
-		product, err := s.repo.Product.GetByID(order.OrderItems[i].ProductID)
+			// Set the price at the time of order
+			order.OrderItems[i].Price = product.Price
+			order.OrderItems[i].ReturnedQuantity = 0
+			order.OrderItems[i].Recalculate()
+			names[product.ID] = product.Name
+		}
+
+		order.CalculateTotals()
+
+		// Number the order in the same transaction, so a failed order does
+		// not use up a number
+		year := time.Now().Year()
+		value, err := tx.Sequence.Next(ctx, orderSequence, year)
 		if err != nil {
-			return nil, fmt.Errorf("product with ID %s not found", order.OrderItems[i].ProductID)
+			return err
 		}
+		order.OrderNumber = fmt.Sprintf("ORD-%d-%06d", year, value)
 
-		if product.CurrentStock < order.OrderItems[i].Quantity {
-			return nil, fmt.Errorf("insufficient stock for product %s. Available: %d, Requested: %d",
-				product.Name, product.CurrentStock, order.OrderItems[i].Quantity)
+		// Create the order
+		if err := tx.Order.Create(ctx, order); err != nil {
+			return err
 		}
 
-		// Set the price at the time of order
-		order.OrderItems[i].Price = product.Price
-		order.OrderItems[i].Subtotal = float64(order.OrderItems[i].Quantity) * product.Price
-		totalAmount += order.OrderItems[i].Subtotal
-	}
+		// Deduct stock in product order so concurrent orders lock rows in the
+		// same sequence. The deduction fails if another order took the stock
+		// since it was checked above.
+		items := make([]models.OrderItem, len(order.OrderItems))
+		copy(items, order.OrderItems)
+		sort.Slice(items, func(i, j int) bool { return items[i].ProductID < items[j].ProductID })
 
-	order.TotalAmount = totalAmount
+		for _, item := range items {
+			err := tx.Product.MoveStock(ctx, &models.StockMovement{
+				ProductID:   item.ProductID,
+				Quantity:    -item.Quantity, // Negative quantity to reduce stock
+				Reason:      models.StockSale,
+				ReferenceID: &order.ID,
+			})
+			if errors.Is(err, utils.ErrInsufficientStock) {
+				return fmt.Errorf("insufficient stock for product %s", names[item.ProductID])
+			}
+			if err != nil {
+				return err
+			}
+		}
 
-	// Deduct stock from products
-	for _, item := range order.OrderItems {
-		err := s.repo.Product.Restock(item.ProductID, -item.Quantity) // Negative quantity to reduce stock
-		if err != nil {
-			return nil, err
+		// Create payment record with status 'UNPAID'
+		payment := &models.Payment{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			OrderID:     order.ID,
+			TotalAmount: order.TotalAmount,
+			AmountPaid:  0,
+			Status:      "unpaid",
 		}
+
+		return tx.Payment.Create(ctx, payment)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Create the order
-	err = s.repo.Order.Create(order)
+	return order, nil
+}
+
+func (s *orderService) GetAllOrders(ctx context.Context, search string) ([]models.Order, error) {
+	return s.repo.Order.GetAll(ctx, strings.TrimSpace(search))
+}
+
+func (s *orderService) GetOrderByID(ctx context.Context, id string) (*models.Order, error) {
+	return s.repo.Order.GetByID(ctx, id)
+}
+
+func (s *orderService) UpdateOrder(ctx context.Context, id string, order *models.Order) (*models.Order, error) {
+	existing, err := s.repo.Order.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	// Only fields without their own flow are taken from the request, and only
+	// when given. Line items, status, payment and invoice change through
+	// their endpoints.
+	if order.Notes != "" {
+		existing.Notes = order.Notes
+	}
+	if !order.OrderDate.IsZero() {
+		existing.OrderDate = order.OrderDate
+	}
+
+	err = s.repo.Order.Update(ctx, existing.ID, existing)
 	if err != nil {
-		// If order creation fails, restore stock
-		for _, item := range order.OrderItems {
-			s.repo.Product.Restock(item.ProductID, item.Quantity) // Positive quantity to restore
-		}
 		return nil, err
 	}
 
-	// Create payment record with status 'UNPAID'
-	payment := &models.Payment{
-		BaseModel:   models.BaseModel{ID: uuid.NewString()},
-		OrderID:     order.ID,
-		TotalAmount: order.TotalAmount,
-		AmountPaid:  0,
-		Status:      "unpaid",
+	return s.repo.Order.GetByID(ctx, existing.ID)
+}
+
+func (s *orderService) AddOrderItem(ctx context.Context, orderID string, productID string, quantity int) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be greater than zero")
+	}
+
+	return s.editOrderItems(ctx, orderID, func(tx *repository.Repository, order *models.Order) error {
+		// Adding a product that is already ordered raises that line's quantity
+		for i := range order.OrderItems {
+			if order.OrderItems[i].ProductID == productID {
+				return setItemQuantity(ctx, tx, order, &order.OrderItems[i], order.OrderItems[i].Quantity+quantity)
+			}
+		}
+
+		product, err := tx.Product.GetByID(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("product with ID %s not found", productID)
+		}
+
+		item := models.OrderItem{
+			OrderID:   order.ID,
+			ProductID: product.ID,
+			Quantity:  quantity,
+			Price:     product.Price, // Price at the time the item was added
+		}
+		item.Recalculate()
+		if err := tx.Order.CreateItem(ctx, &item); err != nil {
+			return err
+		}
+		order.OrderItems = append(order.OrderItems, item)
+
+		return moveItemStock(ctx, tx, order, item.ProductID, quantity)
+	})
+}
+
+func (s *orderService) UpdateOrderItem(ctx context.Context, orderID string, itemID string, quantity int) (*models.Order, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be greater than zero, remove the item instead")
 	}
 
-	err = s.repo.Payment.Create(payment)
+	return s.editOrderItems(ctx, orderID, func(tx *repository.Repository, order *models.Order) error {
+		item := findOrderItem(order, itemID)
+		if item == nil {
+			return utils.ErrOrderItemNotFound
+		}
+
+		return setItemQuantity(ctx, tx, order, item, quantity)
+	})
+}
+
+func (s *orderService) RemoveOrderItem(ctx context.Context, orderID string, itemID string) (*models.Order, error) {
+	return s.editOrderItems(ctx, orderID, func(tx *repository.Repository, order *models.Order) error {
+		item := findOrderItem(order, itemID)
+		if item == nil {
+			return utils.ErrOrderItemNotFound
+		}
+
+		if len(order.OrderItems) == 1 {
+			return errors.New("an order needs at least one item, cancel the order instead")
+		}
+
+		if err := tx.Order.DeleteItem(ctx, item.ID); err != nil {
+			return err
+		}
+
+		if err := moveItemStock(ctx, tx, order, item.ProductID, -item.Quantity); err != nil {
+			return err
+		}
+
+		remaining := order.OrderItems[:0]
+		for _, other := range order.OrderItems {
+			if other.ID != itemID {
+				remaining = append(remaining, other)
+			}
+		}
+		order.OrderItems = remaining
+
+		return nil
+	})
+}
+
+// editOrderItems applies edit to a locked order, then recalculates the order
+// and payment totals and the payment status. Everything runs in one
+// transaction, which is rolled back if the new total falls below the amount
+// already paid.
+func (s *orderService) editOrderItems(ctx context.Context, orderID string, edit func(tx *repository.Repository, order *models.Order) error) (*models.Order, error) {
+	err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		order, err := tx.Order.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return utils.ErrOrderNotFound
+		}
+
+		if order.Status != models.OrderPending && order.Status != models.OrderConfirmed {
+			return utils.ErrOrderNotEditable
+		}
+
+		if err := edit(tx, order); err != nil {
+			return err
+		}
+
+		order.CalculateTotals()
+
+		amountPaid := 0.0
+		if order.Payment != nil {
+			amountPaid = order.Payment.AmountPaid
+		}
+
+		if order.TotalAmount < amountPaid {
+			return fmt.Errorf("%w: new total %.2f, paid %.2f", utils.ErrBelowAmountPaid, order.TotalAmount, amountPaid)
+		}
+
+		return tx.Order.UpdateTotals(ctx, order, paymentStatusFor(order, amountPaid))
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return order, nil
+	return s.repo.Order.GetByID(ctx, orderID)
+}
+
+// setItemQuantity changes the quantity of an order line and moves the
+// difference in or out of stock
+func setItemQuantity(ctx context.Context, tx *repository.Repository, order *models.Order, item *models.OrderItem, quantity int) error {
+	difference := quantity - item.Quantity
+	if difference == 0 {
+		return nil
+	}
+
+	item.Quantity = quantity
+	item.Recalculate()
+	if err := tx.Order.UpdateItem(ctx, item); err != nil {
+		return err
+	}
+
+	return moveItemStock(ctx, tx, order, item.ProductID, difference)
+}
+
+// moveItemStock takes stock for extra ordered quantity, or returns it when
+// the ordered quantity goes down
+func moveItemStock(ctx context.Context, tx *repository.Repository, order *models.Order, productID string, ordered int) error {
+	movement := &models.StockMovement{
+		ProductID:   productID,
+		Quantity:    -ordered,
+		Reason:      models.StockSale,
+		ReferenceID: &order.ID,
+	}
+	if ordered < 0 {
+		movement.Reason = models.StockCancel
+	}
+
+	err := tx.Product.MoveStock(ctx, movement)
+	if errors.Is(err, utils.ErrInsufficientStock) {
+		return fmt.Errorf("%w for product %s", utils.ErrInsufficientStock, productID)
+	}
+	return err
+}
+
+func findOrderItem(order *models.Order, itemID string) *models.OrderItem {
+	for i := range order.OrderItems {
+		if order.OrderItems[i].ID == itemID {
+			return &order.OrderItems[i]
+		}
+	}
+	return nil
 }
 
-func (s *orderService) GetAllOrders() ([]models.Order, error) {
-	return s.repo.Order.GetAll()
+// validateDiscount checks that a discount is a percentage up to 100 or a
+// fixed amount that is not negative
+func validateDiscount(discountType models.DiscountType, value float64) error {
+	if !discountType.IsValid() {
+		return fmt.Errorf("%w: unknown type %q", utils.ErrInvalidDiscount, discountType)
+	}
+	if value < 0 || (discountType == models.DiscountPercentage && value > 100) {
+		return fmt.Errorf("%w: %v", utils.ErrInvalidDiscount, value)
+	}
+	return nil
 }
 
-func (s *orderService) GetOrderByID(id string) (*models.Order, error) {
-	return s.repo.Order.GetByID(id)
+// paymentStatusFor derives the payment status of an order from the amount
+// paid so far and its due date
+func paymentStatusFor(order *models.Order, amountPaid float64) string {
+	switch {
+	case amountPaid > 0 && amountPaid >= order.TotalAmount:
+		return "paid"
+	case order.IsPastDue(time.Now()):
+		return "overdue"
+	case amountPaid > 0:
+		return "partially_paid"
+	default:
+		return "unpaid"
+	}
 }
 
-func (s *orderService) UpdateOrder(id string, order *models.Order) (*models.Order, error) {
-	existing, err := s.repo.Order.GetByID(id)
+func (s *orderService) DeleteOrder(ctx context.Context, id string) error {
+	order, err := s.repo.Order.GetByID(ctx, id)
 	if err != nil {
-		return nil, errors.New("order not found")
+		return utils.ErrOrderNotFound
+	}
+
+	return s.repo.Order.Delete(ctx, order.ID)
+}
+
+// CancelOrder cancels an order. Money paid for it is refunded or added to
+// the reseller's credit, as settlement says.
+func (s *orderService) CancelOrder(ctx context.Context, id string, settlement models.ReturnSettlement) error {
+	if settlement != models.SettleRefund && settlement != models.SettleCredit {
+		return utils.ErrInvalidSettlement
 	}
 
-	order.BaseModel = models.BaseModel{ID: existing.ID} // Preserve the ID
+	order, err := s.repo.Order.GetByID(ctx, id)
+	if err != nil {
+		return utils.ErrOrderNotFound
+	}
+
+	return s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		if err := changeOrderStatus(ctx, tx, order, models.OrderCancelled, ""); err != nil {
+			return err
+		}
+		return cancelEffect(ctx, tx, order, settlement)
+	})
+}
 
-	err = s.repo.Order.Update(id, order)
+// statusEffects holds the work done when an order enters a status. Effects
+// run in the same transaction as the status change.
+var statusEffects = map[models.OrderStatus]func(ctx context.Context, tx *repository.Repository, order *models.Order) error{
+	models.OrderConfirmed: invoiceEffect,
+	models.OrderCancelled: func(ctx context.Context, tx *repository.Repository, order *models.Order) error {
+		return cancelEffect(ctx, tx, order, models.SettleRefund)
+	},
+}
+
+func (s *orderService) UpdateOrderStatus(ctx context.Context, id string, status models.OrderStatus, notes string) (*models.Order, error) {
+	if !status.IsValid() {
+		return nil, utils.ErrInvalidOrderStatus
+	}
+
+	order, err := s.repo.Order.GetByID(ctx, id)
+	if err != nil {
+		return nil, utils.ErrOrderNotFound
+	}
+
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		return transitionOrder(ctx, tx, order, status, notes)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return order, nil
+	return s.repo.Order.GetByID(ctx, order.ID)
 }
 
-func (s *orderService) DeleteOrder(id string) error {
-	return s.repo.Order.Delete(id)
+// transitionOrder moves an order to status and runs the status's effects in
+// the given transaction
+func transitionOrder(ctx context.Context, tx *repository.Repository, order *models.Order, status models.OrderStatus, notes string) error {
+	if err := changeOrderStatus(ctx, tx, order, status, notes); err != nil {
+		return err
+	}
+
+	if effect, ok := statusEffects[status]; ok {
+		return effect(ctx, tx, order)
+	}
+	return nil
+}
+
+// changeOrderStatus moves an order to status without running its effects
+func changeOrderStatus(ctx context.Context, tx *repository.Repository, order *models.Order, status models.OrderStatus, notes string) error {
+	if !order.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s to %s", utils.ErrInvalidStatusTransition, order.Status, status)
+	}
+
+	if err := tx.Order.UpdateStatus(ctx, order.ID, order.Status, status, notes); err != nil {
+		return err
+	}
+	order.Status = status
+	return nil
 }
 
-func (s *orderService) CancelOrder(id string) error {
-	order, err := s.repo.Order.GetByID(id)
+func (s *orderService) GetOrderTimeline(ctx context.Context, id string) ([]models.OrderStatusHistory, error) {
+	order, err := s.repo.Order.GetByID(ctx, id)
 	if err != nil {
-		return errors.New("order not found")
+		return nil, utils.ErrOrderNotFound
 	}
 
-	if order.Status == "cancelled" {
-		return errors.New("order is already cancelled")
+	return s.repo.Order.GetStatusHistory(ctx, order.ID)
+}
+
+// invoiceEffect assigns the next invoice number of the year. Numbers come
+// from a locked sequence in the same transaction as the confirmation, so
+// they have no gaps and never change once assigned.
+func invoiceEffect(ctx context.Context, tx *repository.Repository, order *models.Order) error {
+	if order.InvoiceNumber != nil {
+		return nil
 	}
 
-	// Cancel the order (which will restore stock)
-	err = s.repo.Order.Cancel(id)
+	now := time.Now()
+	value, err := tx.Sequence.Next(ctx, invoiceSequence, now.Year())
 	if err != nil {
 		return err
 	}
 
-	// Delete the associated payment record
-	payment, err := s.repo.Payment.GetByOrderID(id)
-	if err == nil {
-		// If payment exists, delete it
-		// In a real implementation, we might want to soft delete or mark as void
-		// For now, let's just update its status to cancelled
-		payment.Status = "cancelled"
-		err = s.repo.Payment.Update(payment)
+	return tx.Order.SetInvoiceNumber(ctx, order.ID, fmt.Sprintf("INV-%d-%06d", now.Year(), value), now)
+}
+
+func (s *orderService) GetInvoicePDF(ctx context.Context, id string) (*models.Order, []byte, error) {
+	order, err := s.repo.Order.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, utils.ErrOrderNotFound
+	}
+
+	if order.InvoiceNumber == nil {
+		return nil, nil, utils.ErrInvoiceNotIssued
+	}
+
+	document, err := invoice.Render(order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, document, nil
+}
+
+// cancelEffect restores stock and voids the payment of the order. Each
+// installment is voided and its money given back to the reseller, refunded
+// as a CASH_OUT transaction or added to the reseller's credit as settlement
+// says. Installments paid from credit always go back to the credit. The
+// payment history keeps every installment next to the entry that gave back
+// its money.
+func cancelEffect(ctx context.Context, tx *repository.Repository, order *models.Order, settlement models.ReturnSettlement) error {
+	if order.Payment != nil {
+		payment, err := tx.Payment.GetByOrderID(ctx, order.ID)
 		if err != nil {
 			return err
 		}
+
+		for i := range payment.Receipts {
+			receipt := &payment.Receipts[i]
+			if receipt.IsVoided() {
+				continue
+			}
+			if err := settleCancelledReceipt(ctx, tx, order, receipt, settlement); err != nil {
+				return err
+			}
+			if err := voidReceipt(ctx, tx, order, receipt, "Order cancelled"); err != nil {
+				return err
+			}
+		}
 	}
 
-	// Delete any associated CASH_IN transactions for this order
-	// In a real implementation, we would query and delete transactions linked to this order
+	if err := tx.Order.Cancel(ctx, order.ID); err != nil {
+		return err
+	}
 
+	if err := tx.Payment.CancelByOrderID(ctx, order.ID); err != nil {
+		return err
+	}
+
+	// Recalculate the balance with the refunds
+	_, err := tx.Payment.GetBalance(ctx)
+	return err
+}
+
+// settleCancelledReceipt gives the money of an installment of a cancelled
+// order back to the reseller
+func settleCancelledReceipt(ctx context.Context, tx *repository.Repository, order *models.Order, receipt *models.PaymentReceipt, settlement models.ReturnSettlement) error {
+	if receipt.Method == models.PaymentCredit || settlement == models.SettleCredit {
+		return tx.Credit.Create(ctx, &models.ResellerCreditEntry{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			ResellerID:  order.ResellerID,
+			Amount:      receipt.Amount,
+			Type:        models.CreditCancellation,
+			ReferenceID: &receipt.ID,
+			Notes:       fmt.Sprintf("Cancellation of order %s", order.OrderNumber),
+		})
+	}
+
+	transaction := &models.Transaction{
+		Category:    models.Refund,
+		Amount:      receipt.Amount,
+		Description: fmt.Sprintf("Refund for cancelled order %s", order.OrderNumber),
+		ReferenceID: &order.ID,
+		PaymentID:   &receipt.PaymentID,
+	}
+	if err := recordCashOut(ctx, tx, transaction); err != nil {
+		return err
+	}
+	receipt.ReversalTransactionID = &transaction.ID
 	return nil
 }
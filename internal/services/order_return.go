@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+// CreateReturn records goods coming back from a shipped or completed order.
+// Returned goods are restocked or written off, the order and payment totals
+// drop by their value, and whatever was already paid for them is refunded
+// as a CASH_OUT transaction or added to the reseller's credit.
+func (s *orderService) CreateReturn(ctx context.Context, orderID string, req *models.CreateReturnRequest) (*models.OrderReturn, error) {
+	if req.Settlement != models.SettleRefund && req.Settlement != models.SettleCredit {
+		return nil, utils.ErrInvalidSettlement
+	}
+
+	if len(req.Items) == 0 {
+		return nil, errors.New("at least one item is required")
+	}
+
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, errors.New("returned quantity must be greater than zero")
+		}
+		if item.Disposition != models.ReturnRestock && item.Disposition != models.ReturnWriteOff {
+			return nil, errors.New("disposition must be restock or write_off")
+		}
+	}
+
+	orderReturn := &models.OrderReturn{
+		BaseModel:  models.BaseModel{ID: uuid.NewString()},
+		OrderID:    orderID,
+		Reason:     req.Reason,
+		Settlement: req.Settlement,
+	}
+
+	err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		order, err := tx.Order.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return utils.ErrOrderNotFound
+		}
+		orderReturn.OrderID = order.ID
+
+		if order.Status != models.OrderShipped && order.Status != models.OrderCompleted {
+			return utils.ErrOrderNotReturnable
+		}
+
+		orderReturn.ResellerID = order.ResellerID
+
+		for _, requested := range req.Items {
+			item := findOrderItem(order, requested.OrderItemID)
+			if item == nil {
+				return utils.ErrOrderItemNotFound
+			}
+
+			if kept := item.Quantity - item.ReturnedQuantity; requested.Quantity > kept {
+				return fmt.Errorf("cannot return %d of order item %s, only %d left", requested.Quantity, item.ID, kept)
+			}
+
+			// The returned goods are worth what the reseller paid for them,
+			// after the line discount
+			kept := item.NetSubtotal()
+			item.ReturnedQuantity += requested.Quantity
+			if err := tx.Order.UpdateItemReturned(ctx, item); err != nil {
+				return err
+			}
+
+			returned := models.OrderReturnItem{
+				OrderItemID: item.ID,
+				ProductID:   item.ProductID,
+				Quantity:    requested.Quantity,
+				Disposition: requested.Disposition,
+				Amount:      kept - item.NetSubtotal(),
+			}
+			orderReturn.Items = append(orderReturn.Items, returned)
+			orderReturn.Amount += returned.Amount
+		}
+
+		// Whatever was paid above the new total belongs to the reseller
+		order.CalculateTotals()
+		totalAmount := order.TotalAmount
+		amountPaid := 0.0
+		if order.Payment != nil {
+			amountPaid = order.Payment.AmountPaid
+		}
+		if amountPaid > totalAmount {
+			orderReturn.SettledAmount = amountPaid - totalAmount
+			amountPaid = totalAmount
+		}
+
+		if orderReturn.SettledAmount > 0 && req.Settlement == models.SettleRefund {
+			refund, err := recordRefund(ctx, tx, order, orderReturn.SettledAmount)
+			if err != nil {
+				return err
+			}
+			orderReturn.TransactionID = &refund.ID
+		}
+
+		if err := tx.Order.CreateReturn(ctx, orderReturn); err != nil {
+			return err
+		}
+
+		for _, returned := range orderReturn.Items {
+			if returned.Disposition != models.ReturnRestock {
+				continue
+			}
+			err := tx.Product.MoveStock(ctx, &models.StockMovement{
+				ProductID:   returned.ProductID,
+				Quantity:    returned.Quantity,
+				Reason:      models.StockReturn,
+				ReferenceID: &orderReturn.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if orderReturn.SettledAmount > 0 && req.Settlement == models.SettleCredit {
+			err := tx.Credit.Create(ctx, &models.ResellerCreditEntry{
+				ResellerID:  order.ResellerID,
+				Amount:      orderReturn.SettledAmount,
+				Type:        models.CreditReturn,
+				ReferenceID: &orderReturn.ID,
+				Notes:       req.Reason,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// The settled amount no longer counts as paid for the order
+		if _, err := tx.Payment.RefreshAmountPaid(ctx, order.ID); err != nil {
+			return err
+		}
+
+		return tx.Order.UpdateTotals(ctx, order, paymentStatusFor(order, amountPaid))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orderReturn, nil
+}
+
+func (s *orderService) GetReturns(ctx context.Context, orderID string) ([]models.OrderReturn, error) {
+	order, err := s.repo.Order.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, utils.ErrOrderNotFound
+	}
+
+	return s.repo.Order.GetReturns(ctx, order.ID)
+}
+
+// recordRefund pays money back to a reseller as a CASH_OUT transaction
+func recordRefund(ctx context.Context, tx *repository.Repository, order *models.Order, amount float64) (*models.Transaction, error) {
+	transaction := &models.Transaction{
+		Category:    models.Refund,
+		Amount:      amount,
+		Description: fmt.Sprintf("Refund for returned goods of order %s", order.OrderNumber),
+		ReferenceID: &order.ID,
+	}
+	if order.Payment != nil {
+		transaction.PaymentID = &order.Payment.ID
+	}
+
+	if err := recordCashOut(ctx, tx, transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// recordCashOut saves a CASH_OUT transaction if the balance covers it and
+// recalculates the balance
+func recordCashOut(ctx context.Context, tx *repository.Repository, transaction *models.Transaction) error {
+	balance, err := tx.Payment.GetBalance(ctx)
+	if err != nil {
+		return err
+	}
+
+	if balance.CurrentBalance < transaction.Amount {
+		return fmt.Errorf("%w for a cash-out of %.2f", utils.ErrInsufficientBalance, transaction.Amount)
+	}
+
+	transaction.BaseModel = models.BaseModel{ID: uuid.NewString()}
+	transaction.Type = models.CashOut
+	transaction.Date = time.Now()
+
+	if err := tx.Payment.CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+
+	// Recalculate the balance with the cash-out
+	_, err = tx.Payment.GetBalance(ctx)
+	return err
+}
@@ -1,12 +1,16 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aryadhira/reseller-management/internal/interfaces"
 	"github.com/aryadhira/reseller-management/internal/models"
 	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"github.com/google/uuid"
 )
 
@@ -18,95 +22,276 @@ func NewPaymentService(repo *repository.Repository) *paymentService {
 	return &paymentService{repo: repo}
 }
 
-func (s *paymentService) GetAllPayments() ([]models.Payment, error) {
-	return s.repo.Payment.GetAll()
+func (s *paymentService) GetAllPayments(ctx context.Context) ([]models.Payment, error) {
+	return s.repo.Payment.GetAll(ctx)
 }
 
-func (s *paymentService) GetPaymentByOrderID(orderID string) (*models.Payment, error) {
-	return s.repo.Payment.GetByOrderID(orderID)
+func (s *paymentService) GetPaymentByOrderID(ctx context.Context, orderID string) (*models.Payment, error) {
+	return s.repo.Payment.GetByOrderID(ctx, orderID)
 }
 
-func (s *paymentService) RecordPayment(orderID string, amount float64, notes string) (*models.Payment, error) {
-	// Get the order and its payment
-	order, err := s.repo.Order.GetByID(orderID)
-	if err != nil {
-		return nil, errors.New("order not found")
-	}
-	
-	payment, err := s.repo.Payment.GetByOrderID(orderID)
+// RecordPayment records an installment received for an order. Each
+// installment gets a receipt and a CASH_IN transaction, and the amount paid
+// is derived again from the receipts. Money received above the remaining
+// amount is added to the reseller's credit, and installments paid from credit
+// take the amount from the credit instead of bringing in cash.
+func (s *paymentService) RecordPayment(ctx context.Context, orderID string, receipt *models.PaymentReceipt) (*models.Payment, error) {
+	err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		return recordPayment(ctx, tx, orderID, receipt)
+	})
 	if err != nil {
-		return nil, errors.New("payment record not found for order")
+		return nil, err
 	}
-	
+
+	return s.repo.Payment.GetByOrderID(ctx, orderID)
+}
+
+// recordPayment does the work of RecordPayment inside the transaction tx, so
+// other flows can record payments together with their own changes
+func recordPayment(ctx context.Context, tx *repository.Repository, orderID string, receipt *models.PaymentReceipt) error {
 	// Check if the payment amount is valid
-	if amount <= 0 {
-		return nil, errors.New("payment amount must be greater than zero")
+	if receipt.Amount <= 0 {
+		return errors.New("payment amount must be greater than zero")
 	}
-	
-	if amount > (payment.TotalAmount - payment.AmountPaid) {
-		return nil, errors.New("payment amount exceeds remaining balance")
+
+	if receipt.Method == "" {
+		receipt.Method = models.PaymentCash
 	}
-	
-	// Update the payment record
-	payment.AmountPaid += amount
-	
-	// Update the payment status
-	if payment.AmountPaid >= payment.TotalAmount {
-		payment.Status = "paid"
-		order.PaymentStatus = "paid"
-	} else if payment.AmountPaid > 0 {
-		payment.Status = "partially_paid"
-		order.PaymentStatus = "partially_paid"
+	if !receipt.Method.IsValid() {
+		return utils.ErrInvalidPaymentMethod
 	}
-	
-	// Update the order payment status
-	err = s.repo.Order.Update(order.ID, order)
+
+	now := time.Now()
+	if receipt.ReceivedAt.IsZero() {
+		receipt.ReceivedAt = now
+	}
+	if receipt.ReceivedAt.After(now) {
+		return errors.New("received date cannot be in the future")
+	}
+
+	// Get the order and its payment
+	order, err := tx.Order.GetForUpdate(ctx, orderID)
 	if err != nil {
-		return nil, err
+		return utils.ErrOrderNotFound
 	}
-	
-	// Update the payment record
-	err = s.repo.Payment.Update(payment)
+	if order.Status == models.OrderCancelled {
+		return utils.ErrOrderCancelled
+	}
+
+	payment := order.Payment
+	if payment == nil {
+		return utils.ErrPaymentNotFound
+	}
+
+	remaining := payment.TotalAmount - payment.AmountPaid
+	if remaining <= 0 {
+		return utils.ErrOrderFullyPaid
+	}
+
+	// Whatever is paid above the remaining amount goes to the reseller's credit
+	var overpaid float64
+	if receipt.Amount > remaining {
+		if receipt.Method == models.PaymentCredit {
+			return utils.ErrCreditOverpayment
+		}
+		overpaid = receipt.Amount - remaining
+		receipt.Amount = remaining
+	}
+
+	receipt.BaseModel = models.BaseModel{ID: uuid.NewString()}
+	receipt.PaymentID = payment.ID
+	receipt.OrderID = order.ID
+
+	if receipt.Method == models.PaymentCredit {
+		if err := spendCredit(ctx, tx, order, receipt); err != nil {
+			return err
+		}
+	} else {
+		// Create a CASH_IN transaction record
+		transaction := &models.Transaction{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			Type:        models.CashIn,
+			Category:    models.PaymentTran,
+			Amount:      receipt.Amount,
+			Description: receipt.Notes,
+			Date:        receipt.ReceivedAt,
+			ReferenceID: &order.ID,
+			PaymentID:   &payment.ID,
+		}
+		if err := tx.Payment.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		receipt.TransactionID = &transaction.ID
+	}
+
+	if err := applyReceipt(ctx, tx, order, receipt); err != nil {
+		return err
+	}
+
+	if overpaid > 0 {
+		description := fmt.Sprintf("Overpayment of order %s", order.OrderNumber)
+		transaction, err := recordCreditCashIn(ctx, tx, order.ResellerID, overpaid, description)
+		if err != nil {
+			return err
+		}
+		err = tx.Credit.Create(ctx, &models.ResellerCreditEntry{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			ResellerID:  order.ResellerID,
+			Amount:      overpaid,
+			Type:        models.CreditOverpayment,
+			ReferenceID: &transaction.ID,
+			Notes:       description,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Recalculate the balance with the cash-in
+	_, err = tx.Payment.GetBalance(ctx)
+	return err
+}
+
+// VoidPayment voids an installment recorded by mistake. The CASH_IN
+// transaction of the installment is reversed with a CASH_OUT entry and the
+// amount paid and payment status of the order are derived again. The voided
+// receipt stays in the payment history.
+func (s *paymentService) VoidPayment(ctx context.Context, receiptID, reason string) (*models.Payment, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, utils.ErrVoidReasonRequired
+	}
+
+	var orderID string
+	err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		receipt, err := tx.Payment.GetReceiptForUpdate(ctx, receiptID)
+		if err != nil {
+			return utils.ErrReceiptNotFound
+		}
+		if receipt.IsVoided() {
+			return utils.ErrReceiptVoided
+		}
+
+		order, err := tx.Order.GetForUpdate(ctx, receipt.OrderID)
+		if err != nil {
+			return utils.ErrOrderNotFound
+		}
+		orderID = order.ID
+
+		if err := reverseReceipt(ctx, tx, order, receipt, "Voided payment: "+reason); err != nil {
+			return err
+		}
+		if err := voidReceipt(ctx, tx, order, receipt, reason); err != nil {
+			return err
+		}
+
+		// Recalculate the balance with the reversal
+		_, err = tx.Payment.GetBalance(ctx)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	
-	// Create a CASH_IN transaction record
+
+	return s.repo.Payment.GetByOrderID(ctx, orderID)
+}
+
+// reverseReceipt takes back what a receipt brought in, described by
+// description. An installment paid from credit is given back to the
+// reseller's credit, other installments are reversed with a CASH_OUT entry.
+func reverseReceipt(ctx context.Context, tx *repository.Repository, order *models.Order, receipt *models.PaymentReceipt, description string) error {
+	if receipt.Method == models.PaymentCredit {
+		// No money came in, the spent credit is given back instead
+		return tx.Credit.Create(ctx, &models.ResellerCreditEntry{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			ResellerID:  order.ResellerID,
+			Amount:      receipt.Amount,
+			Type:        models.CreditReversal,
+			ReferenceID: &receipt.ID,
+			Notes:       description,
+		})
+	}
+
+	// The reversal is not checked against the balance, the money it takes out
+	// was never really received
 	transaction := &models.Transaction{
 		BaseModel:   models.BaseModel{ID: uuid.NewString()},
-		Type:        models.CashIn,
-		Amount:      amount,
-		Description: notes,
+		Type:        models.CashOut,
+		Category:    models.Reversal,
+		Amount:      receipt.Amount,
+		Description: description,
 		Date:        time.Now(),
-		ReferenceID: &orderID,
-		PaymentID:   &payment.ID,
+		ReferenceID: &order.ID,
+		PaymentID:   &receipt.PaymentID,
 	}
-	
-	err = s.repo.Payment.CreateTransaction(transaction)
+	if err := tx.Payment.CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	receipt.ReversalTransactionID = &transaction.ID
+	return nil
+}
+
+// voidReceipt marks a receipt voided for reason and derives the amount paid
+// and payment status of the order again. What the receipt brought in must
+// already be taken back.
+func voidReceipt(ctx context.Context, tx *repository.Repository, order *models.Order, receipt *models.PaymentReceipt, reason string) error {
+	voidedAt := time.Now()
+	receipt.VoidedAt = &voidedAt
+	receipt.VoidReason = reason
+	if err := tx.Payment.VoidReceipt(ctx, receipt); err != nil {
+		return err
+	}
+
+	amountPaid, err := tx.Payment.RefreshAmountPaid(ctx, order.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	// Update the balance
-	balance, err := s.repo.Payment.GetBalance()
+
+	return tx.Order.UpdateTotals(ctx, order, paymentStatusFor(order, amountPaid))
+}
+
+// applyReceipt saves a receipt for an order and derives the amount paid and
+// payment status of the order again
+func applyReceipt(ctx context.Context, tx *repository.Repository, order *models.Order, receipt *models.PaymentReceipt) error {
+	if err := tx.Payment.CreateReceipt(ctx, receipt); err != nil {
+		return err
+	}
+
+	amountPaid, err := tx.Payment.RefreshAmountPaid(ctx, order.ID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	balance.CurrentBalance += amount
-	err = s.repo.Payment.UpdateBalance(balance.InitialBalance)
+
+	// Update the payment status, a partial payment leaves an overdue order overdue
+	return tx.Order.UpdateTotals(ctx, order, paymentStatusFor(order, amountPaid))
+}
+
+// spendCredit takes the amount of a receipt from the credit of the order's
+// reseller
+func spendCredit(ctx context.Context, tx *repository.Repository, order *models.Order, receipt *models.PaymentReceipt) error {
+	available, err := tx.Credit.GetBalanceForUpdate(ctx, order.ResellerID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	return payment, nil
+	if available < receipt.Amount {
+		return fmt.Errorf("%w: %.2f available", utils.ErrInsufficientCredit, available)
+	}
+
+	return tx.Credit.Create(ctx, &models.ResellerCreditEntry{
+		BaseModel:   models.BaseModel{ID: uuid.NewString()},
+		ResellerID:  order.ResellerID,
+		Amount:      -receipt.Amount,
+		Type:        models.CreditPayment,
+		ReferenceID: &receipt.ID,
+		Notes:       fmt.Sprintf("Payment of order %s", order.OrderNumber),
+	})
 }
 
-func (s *paymentService) GetAllTransactions() ([]models.Transaction, error) {
-	return s.repo.Payment.GetAllTransactions()
+func (s *paymentService) GetAllTransactions(ctx context.Context) ([]models.Transaction, error) {
+	return s.repo.Payment.GetAllTransactions(ctx)
 }
 
-func (s *paymentService) RecordCashIn(amount float64, description string, referenceID *string) (*models.Transaction, error) {
+func (s *paymentService) RecordCashIn(ctx context.Context, amount float64, description string, referenceID *string) (*models.Transaction, error) {
 	if amount <= 0 {
 		return nil, errors.New("amount must be greater than zero")
 	}
@@ -121,19 +306,19 @@ func (s *paymentService) RecordCashIn(amount float64, description string, refere
 		ReferenceID: referenceID,
 	}
 	
-	err := s.repo.Payment.CreateTransaction(transaction)
+	err := s.repo.Payment.CreateTransaction(ctx, transaction)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Update the balance
-	balance, err := s.repo.Payment.GetBalance()
+	balance, err := s.repo.Payment.GetBalance(ctx)
 	if err != nil {
 		return nil, err
 	}
 	
 	balance.CurrentBalance += amount
-	err = s.repo.Payment.UpdateBalance(balance.InitialBalance)
+	err = s.repo.Payment.UpdateBalance(ctx, balance.InitialBalance)
 	if err != nil {
 		return nil, err
 	}
@@ -141,13 +326,13 @@ func (s *paymentService) RecordCashIn(amount float64, description string, refere
 	return transaction, nil
 }
 
-func (s *paymentService) RecordCashOut(category models.TransactionCategory, amount float64, description string) (*models.Transaction, error) {
+func (s *paymentService) RecordCashOut(ctx context.Context, category models.TransactionCategory, amount float64, description string) (*models.Transaction, error) {
 	if amount <= 0 {
 		return nil, errors.New("amount must be greater than zero")
 	}
 	
 	// Check if there's enough balance for the cash out
-	balance, err := s.repo.Payment.GetBalance()
+	balance, err := s.repo.Payment.GetBalance(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -165,14 +350,14 @@ func (s *paymentService) RecordCashOut(category models.TransactionCategory, amou
 		Date:        time.Now(),
 	}
 	
-	err = s.repo.Payment.CreateTransaction(transaction)
+	err = s.repo.Payment.CreateTransaction(ctx, transaction)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Update the balance
 	balance.CurrentBalance -= amount
-	err = s.repo.Payment.UpdateBalance(balance.InitialBalance)
+	err = s.repo.Payment.UpdateBalance(ctx, balance.InitialBalance)
 	if err != nil {
 		return nil, err
 	}
@@ -180,22 +365,38 @@ func (s *paymentService) RecordCashOut(category models.TransactionCategory, amou
 	return transaction, nil
 }
 
-func (s *paymentService) UpdateBalance(initialBalance float64, notes string) error {
-	return s.repo.Payment.UpdateBalance(initialBalance)
+func (s *paymentService) UpdateBalance(ctx context.Context, userID string, initialBalance float64, notes string) (*models.BalanceAdjustment, error) {
+	adjustment := &models.BalanceAdjustment{
+		BaseModel: models.BaseModel{ID: uuid.NewString()},
+		NewValue:  initialBalance,
+		Notes:     notes,
+		UserID:    userID,
+	}
+
+	err := s.repo.Payment.AdjustInitialBalance(ctx, adjustment)
+	if err != nil {
+		return nil, err
+	}
+
+	return adjustment, nil
+}
+
+func (s *paymentService) GetBalanceHistory(ctx context.Context) ([]models.BalanceAdjustment, error) {
+	return s.repo.Payment.GetBalanceAdjustments(ctx)
 }
 
-func (s *paymentService) GetBalance() (*models.Balance, error) {
-	return s.repo.Payment.GetBalance()
+func (s *paymentService) GetBalance(ctx context.Context) (*models.Balance, error) {
+	return s.repo.Payment.GetBalance(ctx)
 }
 
-func (s *paymentService) GetDashboardData() (*interfaces.DashboardData, error) {
-	data, err := s.repo.Payment.GetDashboardData()
+func (s *paymentService) GetDashboardData(ctx context.Context) (*interfaces.DashboardData, error) {
+	data, err := s.repo.Payment.GetDashboardData(ctx)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Get low stock products from product repository
-	lowStockProducts, err := s.repo.Product.GetLowStock()
+	lowStockProducts, err := s.repo.Product.GetLowStock(ctx)
 	if err != nil {
 		// If there's an error getting low stock products, continue with an empty list
 		lowStockProducts = []models.Product{}
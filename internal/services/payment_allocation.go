@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+// AllocatePayment spreads one incoming payment of a reseller over several of
+// their orders. The money comes in as a single CASH_IN transaction that every
+// receipt links to. Without an explicit split the unpaid orders are paid
+// oldest due first, and whatever is left goes to the reseller's credit.
+func (s *paymentService) AllocatePayment(ctx context.Context, req *models.PaymentAllocationRequest) (*models.PaymentAllocation, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("payment amount must be greater than zero")
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentCash
+	}
+	if !req.Method.IsValid() || req.Method == models.PaymentCredit {
+		return nil, utils.ErrInvalidPaymentMethod
+	}
+
+	now := time.Now()
+	receivedAt := now
+	if req.ReceivedAt != nil {
+		receivedAt = *req.ReceivedAt
+	}
+	if receivedAt.After(now) {
+		return nil, errors.New("received date cannot be in the future")
+	}
+
+	var requested float64
+	seen := make(map[string]bool, len(req.Allocations))
+	for _, allocation := range req.Allocations {
+		if allocation.Amount <= 0 {
+			return nil, fmt.Errorf("%w: amount for order %s must be greater than zero", utils.ErrInvalidAllocation, allocation.OrderID)
+		}
+		if seen[allocation.OrderID] {
+			return nil, fmt.Errorf("%w: order %s is allocated twice", utils.ErrInvalidAllocation, allocation.OrderID)
+		}
+		seen[allocation.OrderID] = true
+		requested += allocation.Amount
+	}
+	if roundAmount(requested) > req.Amount {
+		return nil, fmt.Errorf("%w: allocations add up to %.2f, more than the %.2f received", utils.ErrInvalidAllocation, requested, req.Amount)
+	}
+
+	reseller, err := s.repo.Reseller.GetByID(ctx, req.ResellerID)
+	if err != nil {
+		return nil, utils.ErrResellerNotFound
+	}
+
+	result := &models.PaymentAllocation{ResellerID: reseller.ID, Amount: req.Amount}
+	err = s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		allocations := req.Allocations
+		if len(allocations) == 0 {
+			unpaid, err := tx.Payment.GetUnpaidOrdersByReseller(ctx, reseller.ID)
+			if err != nil {
+				return err
+			}
+			for _, order := range unpaid {
+				allocations = append(allocations, models.OrderAllocation{OrderID: order.ID})
+			}
+		}
+
+		transaction := &models.Transaction{
+			BaseModel:   models.BaseModel{ID: uuid.NewString()},
+			Type:        models.CashIn,
+			Category:    models.PaymentTran,
+			Amount:      req.Amount,
+			Description: allocationDescription(reseller, req),
+			Date:        receivedAt,
+			ReferenceID: &reseller.ID,
+		}
+		if err := tx.Payment.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		result.TransactionID = transaction.ID
+
+		left := req.Amount
+		for _, allocation := range allocations {
+			if left <= 0 {
+				break
+			}
+
+			order, err := tx.Order.GetForUpdate(ctx, allocation.OrderID)
+			if err != nil {
+				return fmt.Errorf("%w: %s", utils.ErrOrderNotFound, allocation.OrderID)
+			}
+			if order.ResellerID != reseller.ID {
+				return fmt.Errorf("%w: order %s belongs to another reseller", utils.ErrInvalidAllocation, allocation.OrderID)
+			}
+			if order.Status == models.OrderCancelled {
+				return fmt.Errorf("%w: %s", utils.ErrOrderCancelled, allocation.OrderID)
+			}
+			if order.Payment == nil {
+				return utils.ErrPaymentNotFound
+			}
+
+			remaining := roundAmount(order.Payment.TotalAmount - order.Payment.AmountPaid)
+			amount := math.Min(left, remaining)
+			if allocation.Amount > 0 {
+				if remaining <= 0 {
+					return fmt.Errorf("%w: %s", utils.ErrOrderFullyPaid, allocation.OrderID)
+				}
+				if allocation.Amount > remaining {
+					return fmt.Errorf("%w: %.2f is more than the %.2f left to pay on order %s", utils.ErrInvalidAllocation, allocation.Amount, remaining, allocation.OrderID)
+				}
+				amount = allocation.Amount
+			}
+			if amount <= 0 {
+				continue
+			}
+
+			receipt := &models.PaymentReceipt{
+				BaseModel:     models.BaseModel{ID: uuid.NewString()},
+				PaymentID:     order.Payment.ID,
+				OrderID:       order.ID,
+				Amount:        amount,
+				Method:        req.Method,
+				Reference:     req.Reference,
+				ReceivedAt:    receivedAt,
+				Notes:         req.Notes,
+				TransactionID: &transaction.ID,
+			}
+			if err := applyReceipt(ctx, tx, order, receipt); err != nil {
+				return err
+			}
+
+			result.Receipts = append(result.Receipts, *receipt)
+			result.Allocated = roundAmount(result.Allocated + amount)
+			left = roundAmount(left - amount)
+		}
+
+		// The leftover is already part of the cash-in, it only needs crediting
+		if left > 0 {
+			err := tx.Credit.Create(ctx, &models.ResellerCreditEntry{
+				BaseModel:   models.BaseModel{ID: uuid.NewString()},
+				ResellerID:  reseller.ID,
+				Amount:      left,
+				Type:        models.CreditOverpayment,
+				ReferenceID: &transaction.ID,
+				Notes:       "Unallocated part of a payment",
+			})
+			if err != nil {
+				return err
+			}
+			result.Credited = left
+		}
+
+		// Recalculate the balance with the cash-in
+		_, err := tx.Payment.GetBalance(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// allocationDescription describes the single transaction of an allocated
+// payment so it can be traced back to the reseller and bank reference
+func allocationDescription(reseller *models.Reseller, req *models.PaymentAllocationRequest) string {
+	description := fmt.Sprintf("Payment from %s via %s", reseller.Name, req.Method)
+	if req.Reference != "" {
+		description += " " + req.Reference
+	}
+	if req.Notes != "" {
+		description += ": " + req.Notes
+	}
+	return description
+}
+
+// roundAmount rounds a money amount to cents, so sums of allocations do not
+// leave fractions of a cent behind
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
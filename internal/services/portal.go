@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aryadhira/reseller-management/internal/interfaces"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
+)
+
+type portalService struct {
+	repo *repository.Repository
+}
+
+func NewPortalService(repo *repository.Repository) *portalService {
+	return &portalService{repo: repo}
+}
+
+func (s *portalService) GetProfile(ctx context.Context, resellerID string) (*models.Reseller, error) {
+	reseller, err := s.repo.Reseller.GetByID(ctx, resellerID)
+	if err != nil {
+		return nil, errors.New("reseller not found")
+	}
+
+	return reseller, nil
+}
+
+func (s *portalService) GetOrders(ctx context.Context, resellerID string) ([]models.Order, error) {
+	reseller, err := s.repo.Reseller.GetWithOrders(ctx, resellerID)
+	if err != nil {
+		return nil, errors.New("reseller not found")
+	}
+
+	return reseller.Orders, nil
+}
+
+func (s *portalService) GetOrder(ctx context.Context, resellerID string, orderID string) (*models.Order, error) {
+	orders, err := s.GetOrders(ctx, resellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only look among the reseller's own orders so other orders are never exposed
+	for i := range orders {
+		if orders[i].ID == orderID {
+			return &orders[i], nil
+		}
+	}
+
+	return nil, errors.New("order not found")
+}
+
+func (s *portalService) GetPayments(ctx context.Context, resellerID string) ([]models.Payment, error) {
+	orders, err := s.GetOrders(ctx, resellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]string, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+	}
+
+	return s.repo.Payment.GetByOrderIDs(ctx, orderIDs)
+}
+
+func (s *portalService) GetBalance(ctx context.Context, resellerID string) (*interfaces.ResellerBalance, error) {
+	orders, err := s.GetOrders(ctx, resellerID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := &interfaces.ResellerBalance{ResellerID: resellerID}
+	for _, order := range orders {
+		if order.Status == "cancelled" {
+			continue
+		}
+
+		balance.TotalOrdered += order.TotalAmount
+		if order.Payment != nil {
+			balance.TotalPaid += order.Payment.AmountPaid
+		}
+		if order.PaymentStatus != "paid" {
+			balance.OpenOrders++
+		}
+	}
+	balance.Outstanding = balance.TotalOrdered - balance.TotalPaid
+
+	return balance, nil
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
 	"github.com/aryadhira/reseller-management/internal/models"
 	"github.com/aryadhira/reseller-management/internal/repository"
@@ -16,10 +18,10 @@ func NewProductService(repo *repository.Repository) *productService {
 	return &productService{repo: repo}
 }
 
-func (s *productService) CreateProduct(product *models.Product) (*models.Product, error) {
+func (s *productService) CreateProduct(ctx context.Context, product *models.Product) (*models.Product, error) {
 	product.BaseModel = models.BaseModel{ID: uuid.NewString()}
 	
-	err := s.repo.Product.Create(product)
+	err := s.repo.Product.Create(ctx, product)
 	if err != nil {
 		return nil, err
 	}
@@ -27,23 +29,23 @@ func (s *productService) CreateProduct(product *models.Product) (*models.Product
 	return product, nil
 }
 
-func (s *productService) GetAllProducts() ([]models.Product, error) {
-	return s.repo.Product.GetAll()
+func (s *productService) GetAllProducts(ctx context.Context) ([]models.Product, error) {
+	return s.repo.Product.GetAll(ctx)
 }
 
-func (s *productService) GetProductByID(id string) (*models.Product, error) {
-	return s.repo.Product.GetByID(id)
+func (s *productService) GetProductByID(ctx context.Context, id string) (*models.Product, error) {
+	return s.repo.Product.GetByID(ctx, id)
 }
 
-func (s *productService) UpdateProduct(id string, product *models.Product) (*models.Product, error) {
-	existing, err := s.repo.Product.GetByID(id)
+func (s *productService) UpdateProduct(ctx context.Context, id string, product *models.Product) (*models.Product, error) {
+	existing, err := s.repo.Product.GetByID(ctx, id)
 	if err != nil {
 		return nil, errors.New("product not found")
 	}
 	
 	product.ID = existing.ID // Preserve the ID
 	
-	err = s.repo.Product.Update(id, product)
+	err = s.repo.Product.Update(ctx, id, product)
 	if err != nil {
 		return nil, err
 	}
@@ -51,9 +53,9 @@ func (s *productService) UpdateProduct(id string, product *models.Product) (*mod
 	return product, nil
 }
 
-func (s *productService) DeleteProduct(id string) error {
+func (s *productService) DeleteProduct(ctx context.Context, id string) error {
 	// Check if the product is used in any orders
-	orders, err := s.getOrdersContainingProduct(id)
+	orders, err := s.getOrdersContainingProduct(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -62,33 +64,94 @@ func (s *productService) DeleteProduct(id string) error {
 		return errors.New("cannot delete product that is used in existing orders")
 	}
 	
-	return s.repo.Product.Delete(id)
+	return s.repo.Product.Delete(ctx, id)
 }
 
-func (s *productService) RestockProduct(id string, quantity int) (*models.Product, error) {
-	_, err := s.repo.Product.GetByID(id)
+func (s *productService) RestockProduct(ctx context.Context, id string, quantity int, notes string) (*models.Product, error) {
+	if quantity <= 0 {
+		return nil, errors.New("restock quantity must be greater than zero")
+	}
+
+	return s.moveStock(ctx, &models.StockMovement{
+		ProductID: id,
+		Quantity:  quantity,
+		Reason:    models.StockRestock,
+		Notes:     notes,
+	})
+}
+
+func (s *productService) AdjustStock(ctx context.Context, id string, quantity int, notes string) (*models.Product, error) {
+	if quantity == 0 {
+		return nil, errors.New("adjustment quantity must not be zero")
+	}
+
+	if notes == "" {
+		return nil, errors.New("notes are required for stock adjustments")
+	}
+
+	product, err := s.repo.Product.GetByID(ctx, id)
 	if err != nil {
 		return nil, errors.New("product not found")
 	}
-	
-	err = s.repo.Product.Restock(id, quantity)
+
+	if product.CurrentStock+quantity < 0 {
+		return nil, fmt.Errorf("adjustment would make stock negative. Available: %d", product.CurrentStock)
+	}
+
+	return s.moveStock(ctx, &models.StockMovement{
+		ProductID: id,
+		Quantity:  quantity,
+		Reason:    models.StockAdjustment,
+		Notes:     notes,
+	})
+}
+
+func (s *productService) GetStockMovements(ctx context.Context, id string) ([]models.StockMovement, error) {
+	if _, err := s.repo.Product.GetByID(ctx, id); err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	return s.repo.Product.GetMovements(ctx, id)
+}
+
+func (s *productService) CheckStock(ctx context.Context, id string) (*models.StockCheck, error) {
+	product, err := s.repo.Product.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	total, err := s.repo.Product.GetMovementTotal(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	
-	updatedProduct, err := s.repo.Product.GetByID(id)
+
+	return &models.StockCheck{
+		ProductID:     product.ID,
+		CurrentStock:  product.CurrentStock,
+		MovementTotal: total,
+		Consistent:    product.CurrentStock == total,
+	}, nil
+}
+
+func (s *productService) moveStock(ctx context.Context, movement *models.StockMovement) (*models.Product, error) {
+	_, err := s.repo.Product.GetByID(ctx, movement.ProductID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	err = s.repo.Product.MoveStock(ctx, movement)
 	if err != nil {
 		return nil, err
 	}
-	
-	return updatedProduct, nil
+
+	return s.repo.Product.GetByID(ctx, movement.ProductID)
 }
 
-func (s *productService) GetLowStockProducts() ([]models.Product, error) {
-	return s.repo.Product.GetLowStock()
+func (s *productService) GetLowStockProducts(ctx context.Context) ([]models.Product, error) {
+	return s.repo.Product.GetLowStock(ctx)
 }
 
-func (s *productService) getOrdersContainingProduct(productID string) ([]models.Order, error) {
+func (s *productService) getOrdersContainingProduct(ctx context.Context, productID string) ([]models.Order, error) {
 	// This would need to be implemented to check if any orders contain this product
 	// For now, we'll return an empty list
 	// In a real implementation, we'd need to query orders that contain this product
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 
 	"github.com/aryadhira/reseller-management/internal/models"
@@ -16,10 +17,14 @@ func NewResellerService(repo *repository.Repository) *resellerService {
 	return &resellerService{repo: repo}
 }
 
-func (s *resellerService) CreateReseller(reseller *models.Reseller) (*models.Reseller, error) {
+func (s *resellerService) CreateReseller(ctx context.Context, reseller *models.Reseller) (*models.Reseller, error) {
 	reseller.BaseModel = models.BaseModel{ID: uuid.NewString()}
+
+	if reseller.PaymentTermDays < 0 {
+		return nil, errors.New("payment term days cannot be negative")
+	}
 	
-	err := s.repo.Reseller.Create(reseller)
+	err := s.repo.Reseller.Create(ctx, reseller)
 	if err != nil {
 		return nil, err
 	}
@@ -27,23 +32,27 @@ func (s *resellerService) CreateReseller(reseller *models.Reseller) (*models.Res
 	return reseller, nil
 }
 
-func (s *resellerService) GetAllResellers() ([]models.Reseller, error) {
-	return s.repo.Reseller.GetAll()
+func (s *resellerService) GetAllResellers(ctx context.Context) ([]models.Reseller, error) {
+	return s.repo.Reseller.GetAll(ctx)
 }
 
-func (s *resellerService) GetResellerByID(id string) (*models.Reseller, error) {
-	return s.repo.Reseller.GetByID(id)
+func (s *resellerService) GetResellerByID(ctx context.Context, id string) (*models.Reseller, error) {
+	return s.repo.Reseller.GetByID(ctx, id)
 }
 
-func (s *resellerService) UpdateReseller(id string, reseller *models.Reseller) (*models.Reseller, error) {
-	existing, err := s.repo.Reseller.GetByID(id)
+func (s *resellerService) UpdateReseller(ctx context.Context, id string, reseller *models.Reseller) (*models.Reseller, error) {
+	existing, err := s.repo.Reseller.GetByID(ctx, id)
 	if err != nil {
 		return nil, errors.New("reseller not found")
 	}
 	
 	reseller.BaseModel = models.BaseModel{ID: existing.ID} // Preserve the ID
+
+	if reseller.PaymentTermDays < 0 {
+		return nil, errors.New("payment term days cannot be negative")
+	}
 	
-	err = s.repo.Reseller.Update(id, reseller)
+	err = s.repo.Reseller.Update(ctx, id, reseller)
 	if err != nil {
 		return nil, err
 	}
@@ -51,10 +60,10 @@ func (s *resellerService) UpdateReseller(id string, reseller *models.Reseller) (
 	return reseller, nil
 }
 
-func (s *resellerService) DeleteReseller(id string) error {
-	return s.repo.Reseller.Delete(id)
+func (s *resellerService) DeleteReseller(ctx context.Context, id string) error {
+	return s.repo.Reseller.Delete(ctx, id)
 }
 
-func (s *resellerService) GetResellerWithOrders(id string) (*models.Reseller, error) {
-	return s.repo.Reseller.GetWithOrders(id)
+func (s *resellerService) GetResellerWithOrders(ctx context.Context, id string) (*models.Reseller, error) {
+	return s.repo.Reseller.GetWithOrders(ctx, id)
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"github.com/aryadhira/reseller-management/internal/config"
 	"github.com/aryadhira/reseller-management/internal/interfaces"
 	"github.com/aryadhira/reseller-management/internal/repository"
 )
@@ -10,13 +11,19 @@ type Service struct {
 	Product  interfaces.ProductService
 	Order    interfaces.OrderService
 	Payment  interfaces.PaymentService
+	Portal   interfaces.PortalService
+	Credit   interfaces.CreditService
+	Bank     interfaces.BankStatementService
 }
 
-func NewService(repo *repository.Repository) *Service {
+func NewService(repo *repository.Repository, cfg *config.Config) *Service {
 	return &Service{
 		Reseller: NewResellerService(repo),
 		Product:  NewProductService(repo),
-		Order:    NewOrderService(repo),
+		Order:    NewOrderService(repo, cfg.TaxRate),
 		Payment:  NewPaymentService(repo),
+		Portal:   NewPortalService(repo),
+		Credit:   NewCreditService(repo),
+		Bank:     NewBankStatementService(repo),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
+)
+
+// CreateShipment records goods of a confirmed or shipped order sent to the
+// reseller. A shipment that is already with the courier moves a confirmed
+// order to shipped.
+func (s *orderService) CreateShipment(ctx context.Context, orderID string, req *models.CreateShipmentRequest) (*models.Shipment, error) {
+	if req.Status == "" {
+		req.Status = models.ShipmentPending
+	}
+	if !req.Status.IsValid() {
+		return nil, utils.ErrInvalidShipmentStatus
+	}
+
+	if req.ShippingCost < 0 {
+		return nil, errors.New("shipping cost cannot be negative")
+	}
+
+	shipment := &models.Shipment{
+		BaseModel:      models.BaseModel{ID: uuid.NewString()},
+		Courier:        strings.TrimSpace(req.Courier),
+		TrackingNumber: strings.TrimSpace(req.TrackingNumber),
+		ShippingCost:   req.ShippingCost,
+		Status:         models.ShipmentPending,
+		Notes:          req.Notes,
+	}
+
+	err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		order, err := tx.Order.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return utils.ErrOrderNotFound
+		}
+
+		if order.Status != models.OrderConfirmed && order.Status != models.OrderShipped {
+			return utils.ErrOrderNotShippable
+		}
+		shipment.OrderID = order.ID
+
+		if err := advanceShipment(ctx, tx, order, shipment, req.Status, req.CompleteOrder); err != nil {
+			return err
+		}
+
+		if req.RecordExpense {
+			if err := recordShippingExpense(ctx, tx, order, shipment); err != nil {
+				return err
+			}
+		}
+
+		return tx.Shipment.Create(ctx, shipment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+// UpdateShipment changes a shipment's details or moves it forward. Marking a
+// shipment delivered completes the order when requested.
+func (s *orderService) UpdateShipment(ctx context.Context, orderID string, shipmentID string, req *models.UpdateShipmentRequest) (*models.Shipment, error) {
+	if req.Status != "" && !req.Status.IsValid() {
+		return nil, utils.ErrInvalidShipmentStatus
+	}
+
+	if req.ShippingCost != nil && *req.ShippingCost < 0 {
+		return nil, errors.New("shipping cost cannot be negative")
+	}
+
+	var shipment *models.Shipment
+	err := s.repo.Transaction(ctx, func(tx *repository.Repository) error {
+		order, err := tx.Order.GetForUpdate(ctx, orderID)
+		if err != nil {
+			return utils.ErrOrderNotFound
+		}
+
+		shipment, err = tx.Shipment.GetForUpdate(ctx, order.ID, shipmentID)
+		if err != nil {
+			return utils.ErrShipmentNotFound
+		}
+
+		if req.Courier != nil {
+			shipment.Courier = strings.TrimSpace(*req.Courier)
+		}
+		if req.TrackingNumber != nil {
+			shipment.TrackingNumber = strings.TrimSpace(*req.TrackingNumber)
+		}
+		if req.Notes != nil {
+			shipment.Notes = *req.Notes
+		}
+		if req.ShippingCost != nil && *req.ShippingCost != shipment.ShippingCost {
+			if shipment.TransactionID != nil {
+				return utils.ErrShippingCostRecorded
+			}
+			shipment.ShippingCost = *req.ShippingCost
+		}
+
+		if req.Status != "" {
+			if err := advanceShipment(ctx, tx, order, shipment, req.Status, req.CompleteOrder); err != nil {
+				return err
+			}
+		}
+
+		if req.RecordExpense {
+			if err := recordShippingExpense(ctx, tx, order, shipment); err != nil {
+				return err
+			}
+		}
+
+		return tx.Shipment.Update(ctx, shipment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shipment, nil
+}
+
+func (s *orderService) GetShipments(ctx context.Context, orderID string) ([]models.Shipment, error) {
+	order, err := s.repo.Order.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, utils.ErrOrderNotFound
+	}
+
+	return s.repo.Shipment.GetByOrderID(ctx, order.ID)
+}
+
+// advanceShipment moves a shipment to status, stamping when it was shipped
+// and delivered, and carries the order along: goods with the courier make a
+// confirmed order shipped, and a delivery completes a shipped order when
+// completeOrder is set
+func advanceShipment(ctx context.Context, tx *repository.Repository, order *models.Order, shipment *models.Shipment, status models.ShipmentStatus, completeOrder bool) error {
+	if !shipment.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s to %s", utils.ErrInvalidShipmentStatus, shipment.Status, status)
+	}
+
+	now := time.Now()
+	if status != models.ShipmentPending && shipment.ShippedAt == nil {
+		shipment.ShippedAt = &now
+	}
+	if status == models.ShipmentDelivered && shipment.DeliveredAt == nil {
+		shipment.DeliveredAt = &now
+	}
+	shipment.Status = status
+
+	if status != models.ShipmentPending && order.Status == models.OrderConfirmed {
+		notes := strings.TrimSpace(fmt.Sprintf("Shipped by %s %s", shipment.Courier, shipment.TrackingNumber))
+		if err := transitionOrder(ctx, tx, order, models.OrderShipped, notes); err != nil {
+			return err
+		}
+	}
+
+	if status == models.ShipmentDelivered && completeOrder && order.Status == models.OrderShipped {
+		if err := transitionOrder(ctx, tx, order, models.OrderCompleted, "Delivered"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordShippingExpense records a shipment's cost as a CASH_OUT expense, once
+func recordShippingExpense(ctx context.Context, tx *repository.Repository, order *models.Order, shipment *models.Shipment) error {
+	if shipment.TransactionID != nil || shipment.ShippingCost <= 0 {
+		return nil
+	}
+
+	transaction := &models.Transaction{
+		Category:    models.Shipping,
+		Amount:      shipment.ShippingCost,
+		Description: strings.TrimSpace(fmt.Sprintf("Shipping of order %s %s", order.OrderNumber, shipment.Courier)),
+		ReferenceID: &order.ID,
+	}
+	if err := recordCashOut(ctx, tx, transaction); err != nil {
+		return err
+	}
+
+	shipment.TransactionID = &transaction.ID
+	return nil
+}
@@ -6,14 +6,20 @@ import (
 
 	"github.com/aryadhira/reseller-management/internal/interfaces"
 	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/utils"
+	"github.com/google/uuid"
 )
 
 type userService struct {
-	userRepo interfaces.UserRepository
+	userRepo     interfaces.UserRepository
+	resellerRepo interfaces.ResellerRepository
 }
 
-func NewUserService(userRepo interfaces.UserRepository) interfaces.UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo interfaces.UserRepository, resellerRepo interfaces.ResellerRepository) interfaces.UserService {
+	return &userService{
+		userRepo:     userRepo,
+		resellerRepo: resellerRepo,
+	}
 }
 
 func (u *userService) GetProfile(ctx context.Context, userID string) (*models.User, error) {
@@ -23,3 +29,82 @@ func (u *userService) GetProfile(ctx context.Context, userID string) (*models.Us
 	}
 	return user, nil
 }
+
+func (u *userService) ListUsers(ctx context.Context) ([]models.User, error) {
+	return u.userRepo.FindAll(ctx)
+}
+
+func (u *userService) CreateUser(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	if !req.Role.IsValid() {
+		return nil, errors.New("invalid role")
+	}
+
+	// Portal accounts must point at an existing reseller; staff accounts must not
+	if req.Role == models.RoleReseller {
+		if req.ResellerID == nil {
+			return nil, errors.New("reseller_id is required for reseller accounts")
+		}
+		if _, err := u.resellerRepo.GetByID(ctx, *req.ResellerID); err != nil {
+			return nil, errors.New("reseller not found")
+		}
+	} else {
+		req.ResellerID = nil
+	}
+
+	existing, err := u.userRepo.FindByEmail(ctx, req.Email)
+	if err == nil && existing != nil {
+		return nil, errors.New("user already exists with this email")
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+
+	user := &models.User{
+		ID:         uuid.NewString(),
+		Name:       req.Name,
+		Email:      req.Email,
+		Password:   hashedPassword,
+		Role:       req.Role,
+		ResellerID: req.ResellerID,
+	}
+
+	if err := u.userRepo.Create(ctx, user); err != nil {
+		return nil, errors.New("failed to create user")
+	}
+
+	return user, nil
+}
+
+func (u *userService) UpdateRole(ctx context.Context, actorID string, userID string, role models.Role) (*models.User, error) {
+	// Only roles with a permission set can be assigned
+	if !role.IsValid() {
+		return nil, errors.New("invalid role")
+	}
+
+	// Prevent owners from locking themselves out
+	if actorID == userID {
+		return nil, errors.New("cannot change your own role")
+	}
+
+	user, err := u.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	// Portal accounts and staff accounts cannot be converted into each other
+	if (user.Role == models.RoleReseller) != (role == models.RoleReseller) {
+		return nil, errors.New("cannot convert between reseller and staff accounts")
+	}
+	if role == models.RoleReseller && user.ResellerID == nil {
+		return nil, errors.New("account is not linked to a reseller")
+	}
+
+	if err := u.userRepo.UpdateRole(ctx, userID, role); err != nil {
+		return nil, err
+	}
+
+	user.Role = role
+	return user, nil
+}
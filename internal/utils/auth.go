@@ -1,6 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	"github.com/aryadhira/reseller-management/internal/models"
@@ -24,11 +29,14 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(user *models.User, tokenSecret string, expiration time.Duration) (string, error) {
+// GenerateToken issues a signed access token. tokenID becomes the jti claim
+// and identifies the session the token belongs to.
+func GenerateToken(user *models.User, tokenID string, tokenSecret string, expiration time.Duration) (string, error) {
 	claims := &Claims{
 		UserID: user.ID,
 		Email:  user.Email,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   user.Email,
@@ -43,6 +51,9 @@ func ValidateToken(tokenString, tokenSecret string) (*Claims, error) {
 	claims := &Claims{}
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
 		return []byte(tokenSecret), nil
 	})
 
@@ -52,3 +63,30 @@ func ValidateToken(tokenString, tokenSecret string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// GenerateRefreshToken returns a random opaque refresh token
+func GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// APIKeyPrefix marks opaque API keys so they can be told apart from JWTs
+const APIKeyPrefix = "rk_"
+
+// GenerateAPIKey returns a random API key
+func GenerateAPIKey() (string, error) {
+	token, err := GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + token, nil
+}
+
+// HashToken returns the SHA-256 hex digest used to store opaque tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package utils
+
+import "context"
+
+type contextKey string
+
+const (
+	tenantIDKey   contextKey = "tenantID"
+	allTenantsKey contextKey = "allTenants"
+	actorKey      contextKey = "actor"
+	skipAuditKey  contextKey = "skipAudit"
+)
+
+// Actor identifies who is making the changes in the current request
+type Actor struct {
+	UserID   string
+	APIKeyID string // Set when the request was authenticated with an API key
+}
+
+// WithTenantID returns a copy of ctx carrying the tenant of the current request
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// TenantIDFromContext returns the tenant stored in ctx, if any
+func TenantIDFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantID, ok := ctx.Value(tenantIDKey).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// WithAllTenants returns a copy of ctx whose statements may reach the rows of
+// every tenant. Without a tenant or this marker, statements on tenant data
+// fail. It is meant for work that spans tenants, such as login lookups and
+// background jobs.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allTenantsKey, true)
+}
+
+// AllTenantsAllowed reports whether ctx was created by WithAllTenants
+func AllTenantsAllowed(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	all, _ := ctx.Value(allTenantsKey).(bool)
+	return all
+}
+
+// WithActor returns a copy of ctx carrying the actor of the current request
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, if any
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+	actor, ok := ctx.Value(actorKey).(Actor)
+	return actor, ok
+}
+
+// WithoutAudit returns a copy of ctx whose changes are not written to the
+// audit log. It is meant for bookkeeping updates such as usage timestamps.
+func WithoutAudit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipAuditKey, true)
+}
+
+// AuditSkipped reports whether ctx was created by WithoutAudit
+func AuditSkipped(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	skip, _ := ctx.Value(skipAuditKey).(bool)
+	return skip
+}
@@ -62,13 +62,13 @@ func Contains(slice []string, item string) bool {
 
 // IsValidTransactionCategory checks if a category is valid
 func IsValidTransactionCategory(category string) bool {
-	validCategories := []string{"RENT", "SALARY", "EQUIPMENT", "PAYMENT", "OTHER"}  // These values must match the constant values in models
+	validCategories := []string{"RENT", "SALARY", "EQUIPMENT", "PAYMENT", "REFUND", "SHIPPING", "OTHER"}  // These values must match the constant values in models
 	return Contains(validCategories, category)
 }
 
 // IsValidOrderStatus checks if status is valid
 func IsValidOrderStatus(status string) bool {
-	validStatuses := []string{"pending", "confirmed", "cancelled", "completed"}
+	validStatuses := []string{"pending", "confirmed", "shipped", "completed", "cancelled"} // These values must match the OrderStatus constants in models
 	return Contains(validStatuses, status)
 }
 
@@ -108,6 +108,40 @@ var (
 	ErrResellerNotFound      = errors.New("reseller not found")
 	ErrPaymentNotFound       = errors.New("payment not found")
 	ErrInvalidOrderStatus    = errors.New("invalid order status")
+	ErrInvalidStatusTransition = errors.New("order cannot move to the requested status")
+	ErrOrderStatusChanged    = errors.New("order status was changed by another request")
+	ErrOrderItemNotFound     = errors.New("order item not found")
+	ErrOrderNotEditable      = errors.New("order items can only be changed while the order is pending or confirmed")
+	ErrBelowAmountPaid       = errors.New("order total cannot fall below the amount already paid")
+	ErrOrderNotReturnable    = errors.New("only shipped or completed orders can be returned")
+	ErrInsufficientBalance   = errors.New("insufficient balance")
+	ErrInvoiceNotIssued      = errors.New("invoice is issued when the order is confirmed")
+	ErrShipmentNotFound      = errors.New("shipment not found")
+	ErrInvalidShipmentStatus = errors.New("invalid shipment status")
+	ErrOrderNotShippable     = errors.New("only confirmed or shipped orders can be shipped")
+	ErrShippingCostRecorded  = errors.New("shipping cost is already recorded as an expense")
+	ErrInvalidDiscount       = errors.New("discount must be a percentage up to 100 or a fixed amount")
+	ErrInvalidSettlement     = errors.New("settlement must be refund or credit")
 	ErrInvalidPaymentStatus  = errors.New("invalid payment status")
+	ErrInvalidPaymentMethod  = errors.New("payment method must be cash, bank_transfer, e_wallet or credit")
+	ErrInsufficientCredit    = errors.New("insufficient reseller credit")
+	ErrOrderFullyPaid        = errors.New("order is already fully paid")
+	ErrOrderCancelled        = errors.New("order is cancelled")
+	ErrCreditOverpayment     = errors.New("a payment from credit cannot exceed the remaining amount")
+	ErrInvalidAllocation     = errors.New("invalid payment allocation")
+	ErrUnknownBankParser     = errors.New("unknown bank statement layout")
+	ErrInvalidBankStatement  = errors.New("bank statement could not be read")
+	ErrBankStatementImported = errors.New("every transfer on the bank statement was already imported")
+	ErrBankStatementNotFound = errors.New("bank statement not found")
+	ErrBankLineNotFound      = errors.New("bank statement line not found")
+	ErrBankLineDecided       = errors.New("bank statement line is already decided")
+	ErrBankLineUnmatched     = errors.New("bank statement line has no order to confirm, pick one")
+	ErrInvalidBankDecision   = errors.New("decision action must be confirm or reject")
+	ErrReceiptNotFound       = errors.New("payment receipt not found")
+	ErrReceiptVoided         = errors.New("payment receipt is already voided")
+	ErrVoidReasonRequired    = errors.New("a reason is required to void a payment")
 	ErrInvalidTransactionCategory = errors.New("invalid transaction category")
+	ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+	ErrSessionRevoked        = errors.New("session has been revoked")
+	ErrTenantRequired        = errors.New("statement on tenant data has no tenant in its context")
 )
\ No newline at end of file
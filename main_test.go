@@ -2,16 +2,30 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/aryadhira/reseller-management/internal/config"
 	"github.com/aryadhira/reseller-management/internal/database"
+	"github.com/aryadhira/reseller-management/internal/jobs"
 	"github.com/aryadhira/reseller-management/internal/middleware"
+	"github.com/aryadhira/reseller-management/internal/models"
+	"github.com/aryadhira/reseller-management/internal/repository"
 	"github.com/aryadhira/reseller-management/internal/routes"
+	"github.com/aryadhira/reseller-management/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestAppInitialization(t *testing.T) {
@@ -35,12 +49,19 @@ func TestAppInitialization(t *testing.T) {
 	middleware.SetupMiddleware(app)
 
 	// Setup routes
-	routes.SetupRoutes(app, db)
+	routes.SetupRoutes(app, db, cfg)
 
-	// Test a basic endpoint
+	// Protected endpoints reject anonymous requests
 	req := httptest.NewRequest("GET", "/api/v1/resellers", nil)
 	resp, err := app.Test(req)
 	assert.NoError(t, err)
+	assert.Equal(t, 401, resp.StatusCode)
+
+	// Test a basic endpoint
+	req = httptest.NewRequest("GET", "/api/v1/resellers", nil)
+	req.Header.Set("Authorization", "Bearer "+authenticate(t, app))
+	resp, err = app.Test(req)
+	assert.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
 }
 
@@ -65,7 +86,7 @@ func TestResellerCRUD(t *testing.T) {
 	middleware.SetupMiddleware(app)
 
 	// Setup routes
-	routes.SetupRoutes(app, db)
+	routes.SetupRoutes(app, db, cfg)
 
 	// Test creating a reseller
 	resellerData := map[string]interface{}{
@@ -78,7 +99,1952 @@ func TestResellerCRUD(t *testing.T) {
 	jsonData, _ := json.Marshal(resellerData)
 	req := httptest.NewRequest("POST", "/api/v1/resellers", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+authenticate(t, app))
 	resp, err := app.Test(req)
 	assert.NoError(t, err)
 	assert.Equal(t, 201, resp.StatusCode)
 }
+
+func TestLogoutRevokesToken(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	send := sender(t, app, authenticate(t, app))
+
+	resp := send("POST", "/api/v1/auth/logout", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// The revoked token can no longer be used
+	resp = send("GET", "/api/v1/me", nil)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestRolePermissions(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	owner := authenticate(t, app)
+	cashier := sender(t, app, authenticateWithRole(t, app, owner, models.RoleCashier, ""))
+	warehouseToken := authenticateWithRole(t, app, owner, models.RoleWarehouse, "")
+	warehouse := sender(t, app, warehouseToken)
+
+	// Warehouse staff only manage products
+	resp := warehouse("GET", "/api/v1/products", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = warehouse("GET", "/api/v1/resellers", nil)
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = warehouse("GET", "/api/v1/orders", nil)
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = warehouse("POST", "/api/v1/orders", map[string]interface{}{})
+	assert.Equal(t, 403, resp.StatusCode)
+
+	// Cashiers take money in but cannot pay it out or manage the shop
+	resp = cashier("GET", "/api/v1/orders", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = cashier("POST", "/api/v1/products", map[string]interface{}{
+		"name":  "Cashier Product",
+		"sku":   fmt.Sprintf("CSH-%d", time.Now().UnixNano()),
+		"price": 100,
+	})
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = cashier("POST", "/api/v1/transactions/cash-out", map[string]interface{}{
+		"amount":      10,
+		"description": "Not allowed",
+	})
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = cashier("PUT", "/api/v1/balance", map[string]interface{}{"initial_balance": 1000})
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = cashier("GET", "/api/v1/admin/users", nil)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	// A role change applies to tokens already issued
+	var user struct {
+		ID string `json:"id"`
+	}
+	resp = warehouse("GET", "/api/v1/me", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&user))
+
+	// Only known roles can be assigned and staff cannot become portal accounts
+	for _, role := range []string{"", "superuser", string(models.RoleReseller)} {
+		resp = sender(t, app, owner)("PUT", "/api/v1/admin/users/"+user.ID+"/role", map[string]interface{}{"role": role})
+		assert.Equal(t, 400, resp.StatusCode, role)
+	}
+
+	resp = sender(t, app, owner)("PUT", "/api/v1/admin/users/"+user.ID+"/role", map[string]interface{}{
+		"role": models.RoleCashier,
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = warehouse("GET", "/api/v1/orders", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = warehouse("POST", "/api/v1/products", map[string]interface{}{
+		"name":  "Former Warehouse Product",
+		"sku":   fmt.Sprintf("FWH-%d", time.Now().UnixNano()),
+		"price": 100,
+	})
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+func TestResellerPortalIsScoped(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	owner := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, owner)
+
+	var product models.Product
+	resp := send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Portal Product",
+		"sku":           fmt.Sprintf("PRT-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	resellers := make([]models.Reseller, 2)
+	orders := make([]models.Order, 2)
+	for i := range resellers {
+		resp = send("POST", "/api/v1/resellers", map[string]interface{}{
+			"name":  fmt.Sprintf("Portal Reseller %d", i),
+			"email": fmt.Sprintf("portal-%d-%d@example.com", i, suffix),
+		})
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&resellers[i]))
+
+		resp = send("POST", "/api/v1/orders", map[string]interface{}{
+			"reseller_id": resellers[i].ID,
+			"order_items": []map[string]interface{}{{"product_id": product.ID, "quantity": 1}},
+		})
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&orders[i]))
+
+		resp = send("POST", "/api/v1/payments/order/"+orders[i].ID+"/pay", map[string]interface{}{"amount": 40})
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	portal := sender(t, app, authenticateWithRole(t, app, owner, models.RoleReseller, resellers[0].ID))
+
+	// The reseller sees its own order but not the other reseller's
+	resp = portal("GET", "/api/v1/portal/orders/"+orders[0].ID, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = portal("GET", "/api/v1/portal/orders/"+orders[1].ID, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	var listed []models.Order
+	resp = portal("GET", "/api/v1/portal/orders", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&listed))
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, orders[0].ID, listed[0].ID)
+	}
+
+	var payments []models.Payment
+	resp = portal("GET", "/api/v1/portal/payments", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payments))
+	if assert.Len(t, payments, 1) {
+		assert.Equal(t, orders[0].ID, payments[0].OrderID)
+		assert.InDelta(t, 40, payments[0].AmountPaid, 0.001)
+	}
+
+	// Back-office routes stay closed to resellers
+	resp = portal("GET", "/api/v1/orders/"+orders[1].ID, nil)
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = portal("GET", "/api/v1/payments/order/"+orders[1].ID, nil)
+	assert.Equal(t, 403, resp.StatusCode)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	send := sender(t, app, authenticate(t, app))
+
+	var created models.CreateAPIKeyResponse
+	resp := send("POST", "/api/v1/api-keys", map[string]interface{}{
+		"name":   "Reseller sync",
+		"scopes": []models.Permission{models.PermResellersRead},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.True(t, strings.HasPrefix(created.Key, utils.APIKeyPrefix))
+
+	withHeader := func(method, path string, body interface{}) *http.Response {
+		req := newJSONRequest(method, path, "", body)
+		req.Header.Set("X-API-Key", created.Key)
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		return resp
+	}
+	withBearer := sender(t, app, created.Key)
+
+	// The key works in both forms, but only within its scopes even though
+	// the issuing owner may do more
+	for _, keySend := range []func(method, path string, body interface{}) *http.Response{withHeader, withBearer} {
+		resp = keySend("GET", "/api/v1/resellers", nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		resp = keySend("POST", "/api/v1/resellers", map[string]interface{}{
+			"name":  "Out Of Scope",
+			"email": fmt.Sprintf("scope-%d@example.com", time.Now().UnixNano()),
+		})
+		assert.Equal(t, 403, resp.StatusCode)
+		resp = keySend("GET", "/api/v1/products", nil)
+		assert.Equal(t, 403, resp.StatusCode)
+	}
+
+	// A revoked key is no longer accepted
+	resp = send("DELETE", "/api/v1/api-keys/"+created.APIKey.ID, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = withHeader("GET", "/api/v1/resellers", nil)
+	assert.Equal(t, 401, resp.StatusCode)
+	resp = withBearer("GET", "/api/v1/resellers", nil)
+	assert.Equal(t, 401, resp.StatusCode)
+}
+
+func TestTenantIsolation(t *testing.T) {
+	app, db := newTestApp(t)
+
+	shopA := authenticateAs(t, app, "owner-a@example.com")
+	shopB := authenticateAs(t, app, "owner-b@example.com")
+
+	// Both shops may use the same reseller email
+	resellerData := map[string]interface{}{
+		"name":  "Shared Reseller",
+		"email": "shared@example.com",
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	for _, token := range []string{shopA, shopB} {
+		resp := sender(t, app, token)("POST", "/api/v1/resellers", resellerData)
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	}
+
+	// Shop A cannot read the reseller created by shop B
+	resp := sender(t, app, shopA)("GET", "/api/v1/resellers/"+created.ID, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	// Statements without a tenant fail instead of seeing every shop
+	repo := repository.NewRepository(db)
+	_, err := repo.Reseller.GetByID(context.Background(), created.ID)
+	assert.ErrorIs(t, err, utils.ErrTenantRequired)
+
+	reseller, err := repo.Reseller.GetByID(utils.WithAllTenants(context.Background()), created.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Shared Reseller", reseller.Name)
+	}
+}
+
+func TestAuditLogRecordsChanges(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	send := sender(t, app, authenticate(t, app))
+
+	resp := send("POST", "/api/v1/products", map[string]interface{}{
+		"name":  "Audited Product",
+		"sku":   fmt.Sprintf("AUD-%d", time.Now().UnixNano()),
+		"price": 100,
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var product struct {
+		ID string `json:"id"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	resp = send("PUT", "/api/v1/products/"+product.ID, map[string]interface{}{"price": 150})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = send("GET", "/api/v1/audit?entity=products&entity_id="+product.ID, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var logs []models.AuditLog
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&logs))
+	if assert.Len(t, logs, 2) {
+		assert.Equal(t, models.AuditActionUpdate, logs[0].Action)
+		assert.Equal(t, float64(150), logs[0].Changes["price"].To)
+		assert.Equal(t, models.AuditActionCreate, logs[1].Action)
+		assert.NotEmpty(t, logs[1].ActorID)
+	}
+}
+
+func TestBalanceAdjustmentHistory(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	send := sender(t, app, authenticateAs(t, app, fmt.Sprintf("balance-%d@example.com", time.Now().UnixNano())))
+
+	for _, amount := range []float64{1000, 1500} {
+		resp := send("PUT", "/api/v1/balance", map[string]interface{}{
+			"initial_balance": amount,
+			"notes":           "Opening cash count",
+		})
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	resp := send("GET", "/api/v1/balance/history", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var history []models.BalanceAdjustment
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&history))
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, float64(1000), history[0].OldValue)
+		assert.Equal(t, float64(1500), history[0].NewValue)
+		assert.Equal(t, float64(500), history[0].Delta)
+		assert.Equal(t, "Opening cash count", history[0].Notes)
+	}
+}
+
+func TestStockMovementLedger(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+
+	send := sender(t, app, token)
+
+	resp := send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Ledger Product",
+		"sku":           fmt.Sprintf("LED-%d", time.Now().UnixNano()),
+		"price":         10,
+		"current_stock": 10,
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var product models.Product
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	resp = send("POST", "/api/v1/products/"+product.ID+"/restock", map[string]interface{}{"quantity": 5})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = send("POST", "/api/v1/products/"+product.ID+"/adjust-stock", map[string]interface{}{"quantity": -2, "notes": "Damaged"})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// Stock cannot be changed through a plain update
+	resp = send("PUT", "/api/v1/products/"+product.ID, map[string]interface{}{"current_stock": 100})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = send("GET", "/api/v1/products/"+product.ID+"/movements", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var movements []models.StockMovement
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&movements))
+	if assert.Len(t, movements, 3) {
+		assert.Equal(t, models.StockAdjustment, movements[0].Reason)
+		assert.Equal(t, 13, movements[0].ResultingStock)
+	}
+
+	resp = send("GET", "/api/v1/products/"+product.ID+"/stock-check", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var check models.StockCheck
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&check))
+	assert.True(t, check.Consistent)
+	assert.Equal(t, 13, check.CurrentStock)
+}
+
+func TestConcurrentOrdersNeverOversell(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Concurrent Reseller",
+		"email": fmt.Sprintf("concurrent-%d@example.com", suffix),
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	const stock = 5
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Limited Product",
+		"sku":           fmt.Sprintf("LIM-%d", suffix),
+		"price":         10,
+		"current_stock": stock,
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	// A negative quantity would add stock instead of taking it
+	for _, quantity := range []int{0, -3} {
+		resp = send("POST", "/api/v1/orders", map[string]interface{}{
+			"reseller_id": reseller.ID,
+			"order_items": []map[string]interface{}{
+				{"product_id": product.ID, "quantity": quantity},
+			},
+		})
+		assert.NotEqual(t, 201, resp.StatusCode)
+	}
+
+	// Place more orders than there is stock, all at once
+	const attempts = 20
+	var wg sync.WaitGroup
+	var created int32
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := send("POST", "/api/v1/orders", map[string]interface{}{
+				"reseller_id": reseller.ID,
+				"order_items": []map[string]interface{}{
+					{"product_id": product.ID, "quantity": 1},
+				},
+			})
+			if resp.StatusCode == 201 {
+				atomic.AddInt32(&created, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(stock), created)
+
+	resp = send("GET", "/api/v1/products/"+product.ID+"/stock-check", nil)
+	var check models.StockCheck
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&check))
+	assert.Equal(t, 0, check.CurrentStock)
+	assert.True(t, check.Consistent)
+}
+
+func TestCancelOrderReversesPayments(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	balance := func() float64 {
+		var b models.Balance
+		resp := send("GET", "/api/v1/balance", nil)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&b))
+		return b.CurrentBalance
+	}
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Cancelling Reseller",
+		"email": fmt.Sprintf("cancel-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Cancelled Product",
+		"sku":           fmt.Sprintf("CAN-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 2},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+
+	before := balance()
+
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 150})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, before+150, balance())
+
+	resp = send("PATCH", "/api/v1/orders/"+order.ID+"/cancel", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// The payment no longer counts towards the balance
+	assert.Equal(t, before, balance())
+
+	// A cancelled order takes no more payments
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 50})
+	assert.Equal(t, 409, resp.StatusCode)
+	assert.Equal(t, before, balance())
+
+	var payment models.Payment
+	resp = send("GET", "/api/v1/payments/order/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "cancelled", payment.Status)
+	assert.Equal(t, float64(0), payment.AmountPaid)
+
+	// The installment stays in the history, voided by the cancellation and
+	// refunded
+	if assert.Len(t, payment.Receipts, 1) {
+		assert.True(t, payment.Receipts[0].IsVoided())
+		assert.Equal(t, "Order cancelled", payment.Receipts[0].VoidReason)
+		assert.NotNil(t, payment.Receipts[0].ReversalTransactionID)
+	}
+
+	var transactions []models.Transaction
+	resp = send("GET", "/api/v1/transactions", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&transactions))
+	refunded := false
+	for _, transaction := range transactions {
+		if transaction.Category == models.Refund && transaction.PaymentID != nil && *transaction.PaymentID == payment.ID {
+			refunded = true
+			assert.Equal(t, models.CashOut, transaction.Type)
+			assert.InDelta(t, 150, transaction.Amount, 0.001)
+		}
+	}
+	assert.True(t, refunded)
+}
+
+func TestOrderStatusTransitions(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Lifecycle Reseller",
+		"email": fmt.Sprintf("lifecycle-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Lifecycle Product",
+		"sku":           fmt.Sprintf("LIF-%d", suffix),
+		"price":         50,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"status":      "completed",
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 1},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, models.OrderPending, order.Status)
+
+	// Orders cannot skip ahead
+	resp = send("PATCH", "/api/v1/orders/"+order.ID+"/status", map[string]interface{}{"status": "shipped"})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	for _, status := range []string{"confirmed", "shipped"} {
+		resp = send("PATCH", "/api/v1/orders/"+order.ID+"/status", map[string]interface{}{"status": status})
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	// Shipped orders can no longer be cancelled
+	resp = send("PATCH", "/api/v1/orders/"+order.ID+"/status", map[string]interface{}{"status": "cancelled"})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = send("GET", "/api/v1/orders/"+order.ID+"/timeline", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var timeline []models.OrderStatusHistory
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&timeline))
+	if assert.Len(t, timeline, 3) {
+		assert.Equal(t, models.OrderPending, timeline[0].ToStatus)
+		assert.Equal(t, models.OrderConfirmed, timeline[1].FromStatus)
+		assert.Equal(t, models.OrderShipped, timeline[2].ToStatus)
+	}
+}
+
+func TestEditOrderItems(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Editing Reseller",
+		"email": fmt.Sprintf("editing-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Editable Product",
+		"sku":           fmt.Sprintf("EDI-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 2},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	itemID := order.OrderItems[0].ID
+
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 150})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// The total may not drop below what was already paid
+	resp = send("PUT", "/api/v1/orders/"+order.ID+"/items/"+itemID, map[string]interface{}{"quantity": 1})
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp = send("PUT", "/api/v1/orders/"+order.ID+"/items/"+itemID, map[string]interface{}{"quantity": 3})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, float64(300), order.TotalAmount)
+	assert.Equal(t, "partially_paid", order.PaymentStatus)
+	if assert.NotNil(t, order.Payment) {
+		assert.Equal(t, float64(300), order.Payment.TotalAmount)
+	}
+
+	// Editing the order itself only changes the editable fields, the lines,
+	// totals and payment status stay as they are
+	resp = send("PUT", "/api/v1/orders/"+order.ID, map[string]interface{}{
+		"notes":          "Leave at the back door",
+		"payment_status": "paid",
+		"total_amount":   1,
+		"reseller_id":    "00000000-0000-0000-0000-000000000000",
+		"order_items": []map[string]interface{}{
+			{"id": itemID, "product_id": product.ID, "quantity": 50, "price": 1, "subtotal": 50},
+		},
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// The response shows the saved order with its totals
+	var edited models.Order
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&edited))
+	assert.Equal(t, float64(300), edited.TotalAmount)
+
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&edited))
+	assert.Equal(t, "Leave at the back door", edited.Notes)
+	assert.Equal(t, "partially_paid", edited.PaymentStatus)
+	assert.Equal(t, float64(300), edited.TotalAmount)
+	assert.Equal(t, reseller.ID, edited.ResellerID)
+	if assert.Len(t, edited.OrderItems, 1) {
+		assert.Equal(t, 3, edited.OrderItems[0].Quantity)
+		assert.Equal(t, float64(100), edited.OrderItems[0].Price)
+	}
+
+	resp = send("GET", "/api/v1/products/"+product.ID+"/stock-check", nil)
+	var check models.StockCheck
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&check))
+	assert.Equal(t, 7, check.CurrentStock)
+	assert.True(t, check.Consistent)
+}
+
+func TestReturnRefundsPaidGoods(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Returning Reseller",
+		"email": fmt.Sprintf("returning-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Returnable Product",
+		"sku":           fmt.Sprintf("RET-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 2},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 200})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	returnRequest := map[string]interface{}{
+		"reason":     "Damaged box",
+		"settlement": "refund",
+		"items": []map[string]interface{}{
+			{"order_item_id": order.OrderItems[0].ID, "quantity": 1, "disposition": "restock"},
+		},
+	}
+
+	// Goods must be delivered before they can be returned
+	resp = send("POST", "/api/v1/orders/"+order.ID+"/returns", returnRequest)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	for _, status := range []string{"confirmed", "shipped"} {
+		resp = send("PATCH", "/api/v1/orders/"+order.ID+"/status", map[string]interface{}{"status": status})
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	// A refund pays cash out, which cashiers may not do
+	cashier := authenticateWithRole(t, app, token, models.RoleCashier, "")
+	resp = sender(t, app, cashier)("POST", "/api/v1/orders/"+order.ID+"/returns", returnRequest)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	resp = send("POST", "/api/v1/orders/"+order.ID+"/returns", returnRequest)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var orderReturn models.OrderReturn
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&orderReturn))
+	assert.Equal(t, float64(100), orderReturn.Amount)
+	assert.Equal(t, float64(100), orderReturn.SettledAmount)
+	assert.NotNil(t, orderReturn.TransactionID)
+
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, float64(100), order.TotalAmount)
+	assert.Equal(t, "paid", order.PaymentStatus)
+	if assert.NotNil(t, order.Payment) {
+		assert.Equal(t, float64(100), order.Payment.AmountPaid)
+	}
+
+	resp = send("GET", "/api/v1/products/"+product.ID+"/stock-check", nil)
+	var check models.StockCheck
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&check))
+	assert.Equal(t, 9, check.CurrentStock)
+	assert.True(t, check.Consistent)
+}
+
+// newTestApp connects to the Postgres database configured in the environment
+// and returns an app with every route set up, together with its database
+func newTestApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	return newTestAppWith(t, config.LoadConfig())
+}
+
+// newTestAppWith is newTestApp with a configuration changed by the test
+func newTestAppWith(t *testing.T, cfg *config.Config) (*fiber.App, *gorm.DB) {
+	db, err := database.ConnectDB(cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	app := fiber.New()
+	routes.SetupRoutes(app, db, cfg)
+	return app, db
+}
+
+// newJSONRequest builds a request with body as JSON, sent with the access
+// token when one is given
+func newJSONRequest(method, path, token string, body interface{}) *http.Request {
+	jsonData, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// sender returns a function that sends JSON requests to app with the token
+func sender(t *testing.T, app *fiber.App, token string) func(method, path string, body interface{}) *http.Response {
+	return func(method, path string, body interface{}) *http.Response {
+		resp, err := app.Test(newJSONRequest(method, path, token, body), -1)
+		assert.NoError(t, err)
+		return resp
+	}
+}
+
+// authenticate registers the test user if needed and returns an access token
+func authenticate(t *testing.T, app *fiber.App) string {
+	return authenticateAs(t, app, "tester@example.com")
+}
+
+// authenticateAs registers a user with its own tenant if needed and returns an access token
+func authenticateAs(t *testing.T, app *fiber.App, email string) string {
+	credentials := map[string]interface{}{
+		"name":     "Test User",
+		"email":    email,
+		"password": "secret123",
+	}
+	send := sender(t, app, "")
+
+	// Registration fails harmlessly when the user already exists
+	send("POST", "/api/v1/auth/register", credentials)
+
+	resp := send("POST", "/api/v1/auth/login", credentials)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body.AccessToken
+}
+
+// authenticateWithRole creates a user with role in the tenant of ownerToken
+// and returns its access token. Reseller accounts are linked to resellerID.
+func authenticateWithRole(t *testing.T, app *fiber.App, ownerToken string, role models.Role, resellerID string) string {
+	email := fmt.Sprintf("%s-%d@example.com", role, time.Now().UnixNano())
+	user := map[string]interface{}{
+		"name":     "Test " + string(role),
+		"email":    email,
+		"password": "secret123",
+		"role":     role,
+	}
+	if resellerID != "" {
+		user["reseller_id"] = resellerID
+	}
+
+	resp := sender(t, app, ownerToken)("POST", "/api/v1/admin/users", user)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	return authenticateAs(t, app, email)
+}
+
+func TestInvoiceNumberIsStable(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Invoice Reseller",
+		"email": fmt.Sprintf("invoice-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Invoice Product",
+		"sku":           fmt.Sprintf("INV-%d", suffix),
+		"price":         75,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 2},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Nil(t, order.InvoiceNumber)
+
+	// Pending orders have no invoice yet
+	resp = send("GET", "/api/v1/orders/"+order.ID+"/invoice.pdf", nil)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp = send("PATCH", "/api/v1/orders/"+order.ID+"/status", map[string]interface{}{"status": "confirmed"})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var confirmed models.Order
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&confirmed))
+	if assert.NotNil(t, confirmed.InvoiceNumber) {
+		assert.Regexp(t, `^INV-\d{4}-\d{6}$`, *confirmed.InvoiceNumber)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp = send("GET", "/api/v1/orders/"+order.ID+"/invoice.pdf", nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+
+		document, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(document, []byte("%PDF-")))
+	}
+
+	// Editing the order cannot change or clear the issued number
+	for _, number := range []interface{}{"INV-1999-000001", nil} {
+		resp = send("PUT", "/api/v1/orders/"+order.ID, map[string]interface{}{
+			"notes":          "Edited after invoicing",
+			"invoice_number": number,
+			"invoiced_at":    nil,
+		})
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	// Re-reading the order never changes its number
+	var again models.Order
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&again))
+	assert.Equal(t, confirmed.InvoiceNumber, again.InvoiceNumber)
+	assert.Equal(t, confirmed.InvoicedAt, again.InvoicedAt)
+}
+
+func TestOrderNumbers(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Numbered Reseller",
+		"email": fmt.Sprintf("numbered-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Numbered Product",
+		"sku":           fmt.Sprintf("NUM-%d", suffix),
+		"price":         20,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	orders := make([]models.Order, 2)
+	for i := range orders {
+		resp = send("POST", "/api/v1/orders", map[string]interface{}{
+			"reseller_id": reseller.ID,
+			"order_items": []map[string]interface{}{
+				{"product_id": product.ID, "quantity": 1},
+			},
+		})
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&orders[i]))
+		assert.Regexp(t, `^ORD-\d{4}-\d{6}$`, orders[i].OrderNumber)
+	}
+	assert.NotEqual(t, orders[0].OrderNumber, orders[1].OrderNumber)
+
+	// The order number works wherever an order ID does
+	var found models.Order
+	resp = send("GET", "/api/v1/orders/"+orders[1].OrderNumber, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&found))
+	assert.Equal(t, orders[1].ID, found.ID)
+
+	var payment models.Payment
+	resp = send("GET", "/api/v1/payments/order/"+orders[1].OrderNumber, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, orders[1].ID, payment.OrderID)
+	assert.Equal(t, orders[1].OrderNumber, payment.Order.OrderNumber)
+
+	var results []models.Order
+	resp = send("GET", "/api/v1/orders?search="+orders[0].OrderNumber, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, orders[0].ID, results[0].ID)
+	}
+}
+
+func TestShipmentDeliveryCompletesOrder(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Shipping Reseller",
+		"email": fmt.Sprintf("shipping-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Shipped Product",
+		"sku":           fmt.Sprintf("SHP-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 1},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 100})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	shipmentRequest := map[string]interface{}{
+		"courier":         "JNE",
+		"tracking_number": fmt.Sprintf("JNE%d", suffix),
+		"shipping_cost":   15,
+		"status":          "shipped",
+		"record_expense":  true,
+	}
+
+	// Pending orders are not shipped yet
+	resp = send("POST", "/api/v1/orders/"+order.ID+"/shipments", shipmentRequest)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp = send("PATCH", "/api/v1/orders/"+order.ID+"/status", map[string]interface{}{"status": "confirmed"})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// Recording the expense pays cash out, which cashiers may not do
+	cashier := authenticateWithRole(t, app, token, models.RoleCashier, "")
+	resp = sender(t, app, cashier)("POST", "/api/v1/orders/"+order.ID+"/shipments", shipmentRequest)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	var shipment models.Shipment
+	resp = send("POST", "/api/v1/orders/"+order.ID+"/shipments", shipmentRequest)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&shipment))
+	assert.Equal(t, models.ShipmentShipped, shipment.Status)
+	assert.NotNil(t, shipment.ShippedAt)
+	assert.NotNil(t, shipment.TransactionID)
+
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, models.OrderShipped, order.Status)
+
+	// The recorded cost can no longer change
+	resp = send("PUT", "/api/v1/orders/"+order.ID+"/shipments/"+shipment.ID, map[string]interface{}{"shipping_cost": 20})
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp = send("PUT", "/api/v1/orders/"+order.ID+"/shipments/"+shipment.ID, map[string]interface{}{
+		"status":         "delivered",
+		"complete_order": true,
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&shipment))
+	assert.Equal(t, models.ShipmentDelivered, shipment.Status)
+	assert.NotNil(t, shipment.DeliveredAt)
+
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, models.OrderCompleted, order.Status)
+	assert.Len(t, order.Shipments, 1)
+
+	// Shipments never move backwards
+	resp = send("PUT", "/api/v1/orders/"+order.ID+"/shipments/"+shipment.ID, map[string]interface{}{"status": "shipped"})
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestOrderDiscountsTaxAndShipping(t *testing.T) {
+	cfg := config.LoadConfig()
+	cfg.TaxRate = 11
+	app, _ := newTestAppWith(t, cfg)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Discounted Reseller",
+		"email": fmt.Sprintf("discounted-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Discounted Product",
+		"sku":           fmt.Sprintf("DSC-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	// Percentage discounts cannot exceed 100
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id":    reseller.ID,
+		"discount_type":  "percentage",
+		"discount_value": 120,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 1},
+		},
+	})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id":    reseller.ID,
+		"discount_type":  "fixed",
+		"discount_value": 30,
+		"shipping_fee":   25,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 2, "discount_type": "percentage", "discount_value": 10},
+		},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+
+	// 2 x 100 less 10% is 180, less 30 is 150, plus 11% tax and 25 shipping
+	if assert.Len(t, order.OrderItems, 1) {
+		assert.Equal(t, float64(200), order.OrderItems[0].Subtotal)
+		assert.Equal(t, float64(20), order.OrderItems[0].DiscountAmount)
+	}
+	assert.Equal(t, float64(180), order.Subtotal)
+	assert.Equal(t, float64(30), order.DiscountAmount)
+	assert.Equal(t, float64(11), order.TaxRate)
+	assert.Equal(t, 16.5, order.TaxAmount)
+	assert.Equal(t, float64(25), order.ShippingFee)
+	assert.Equal(t, 191.5, order.TotalAmount)
+
+	var payment models.Payment
+	resp = send("GET", "/api/v1/payments/order/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, 191.5, payment.TotalAmount)
+
+	// Editing a line recalculates the whole breakdown
+	var edited models.Order
+	resp = send("PUT", "/api/v1/orders/"+order.ID+"/items/"+order.OrderItems[0].ID, map[string]interface{}{"quantity": 1})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&edited))
+	assert.Equal(t, float64(90), edited.Subtotal)
+	assert.Equal(t, 6.6, edited.TaxAmount)
+	assert.Equal(t, 91.6, edited.TotalAmount)
+}
+
+func TestOverdueOrders(t *testing.T) {
+	app, db := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":              "Net 14 Reseller",
+		"email":             fmt.Sprintf("net14-%d@example.com", suffix),
+		"payment_term_days": 14,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+	assert.Equal(t, 14, reseller.PaymentTermDays)
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Termed Product",
+		"sku":           fmt.Sprintf("TRM-%d", suffix),
+		"price":         40,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	items := []map[string]interface{}{{"product_id": product.ID, "quantity": 1}}
+
+	// The payment terms set the due date
+	var onTerms models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{"reseller_id": reseller.ID, "order_items": items})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&onTerms))
+	if assert.NotNil(t, onTerms.DueDate) {
+		assert.WithinDuration(t, onTerms.OrderDate.AddDate(0, 0, 14), *onTerms.DueDate, time.Second)
+	}
+
+	var late models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"due_date":    time.Now().AddDate(0, 0, -3),
+		"order_items": items,
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&late))
+
+	checker := jobs.NewOverdueChecker(repository.NewRepository(db).Payment, time.Hour)
+	marked, err := checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, marked, int64(1))
+
+	resp = send("GET", "/api/v1/orders/"+late.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&late))
+	assert.Equal(t, "overdue", late.PaymentStatus)
+	if assert.NotNil(t, late.Payment) {
+		assert.Equal(t, "overdue", late.Payment.Status)
+	}
+
+	resp = send("GET", "/api/v1/orders/"+onTerms.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&onTerms))
+	assert.Equal(t, "unpaid", onTerms.PaymentStatus)
+
+	// The due date cannot be pushed back by editing the order
+	dueDate := late.DueDate
+	resp = send("PUT", "/api/v1/orders/"+late.ID, map[string]interface{}{
+		"due_date":       time.Now().AddDate(0, 1, 0),
+		"payment_status": "unpaid",
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = send("GET", "/api/v1/orders/"+late.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&late))
+	assert.Equal(t, "overdue", late.PaymentStatus)
+	if assert.NotNil(t, late.DueDate) && assert.NotNil(t, dueDate) {
+		assert.WithinDuration(t, *dueDate, *late.DueDate, time.Second)
+	}
+
+	// A partial payment leaves the order overdue
+	resp = send("POST", "/api/v1/payments/order/"+late.ID+"/pay", map[string]interface{}{"amount": 10})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var dashboard struct {
+		UnpaidOrders []models.Order `json:"unpaid_orders"`
+	}
+	resp = send("GET", "/api/v1/dashboard", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&dashboard))
+
+	position := map[string]int{}
+	for i, order := range dashboard.UnpaidOrders {
+		position[order.ID] = i
+		if order.ID == late.ID {
+			assert.Equal(t, "overdue", order.PaymentStatus)
+			assert.Equal(t, 3, order.DaysOverdue)
+		}
+	}
+	if assert.Contains(t, position, late.ID) && assert.Contains(t, position, onTerms.ID) {
+		assert.Less(t, position[late.ID], position[onTerms.ID])
+	}
+}
+
+func TestIdempotencyKeyReplaysResponses(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	sendAs := func(token, method, path, key string, body interface{}) *http.Response {
+		req := newJSONRequest(method, path, token, body)
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		return resp
+	}
+	send := func(method, path, key string, body interface{}) *http.Response {
+		return sendAs(token, method, path, key, body)
+	}
+
+	// A request refused by the permission check is not stored, so its key
+	// stays free for a request that is allowed
+	resellerRequest := map[string]interface{}{
+		"name":  "Retrying Reseller",
+		"email": fmt.Sprintf("retrying-%d@example.com", suffix),
+	}
+	resellerKey := fmt.Sprintf("reseller-%d", suffix)
+	warehouse := authenticateWithRole(t, app, token, models.RoleWarehouse, "")
+	resp := sendAs(warehouse, "POST", "/api/v1/resellers", resellerKey, resellerRequest)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	var reseller models.Reseller
+	resp = send("POST", "/api/v1/resellers", resellerKey, resellerRequest)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", "", map[string]interface{}{
+		"name":          "Retried Product",
+		"sku":           fmt.Sprintf("IDM-%d", suffix),
+		"price":         30,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	orderRequest := map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{
+			{"product_id": product.ID, "quantity": 2},
+		},
+	}
+	orderKey := fmt.Sprintf("order-%d", suffix)
+
+	orders := make([]models.Order, 2)
+	for i := range orders {
+		resp = send("POST", "/api/v1/orders", orderKey, orderRequest)
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&orders[i]))
+	}
+	assert.Equal(t, orders[0].ID, orders[1].ID)
+	assert.Equal(t, "true", resp.Header.Get(middleware.IdempotentReplayedHeader))
+
+	// The retry did not take stock a second time
+	var stocked models.Product
+	resp = send("GET", "/api/v1/products/"+product.ID, "", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&stocked))
+	assert.Equal(t, 8, stocked.CurrentStock)
+
+	// Reusing the key for a different request is refused
+	orderRequest["notes"] = "changed"
+	resp = send("POST", "/api/v1/orders", orderKey, orderRequest)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	payKey := fmt.Sprintf("pay-%d", suffix)
+	for i := 0; i < 2; i++ {
+		resp = send("POST", "/api/v1/payments/order/"+orders[0].ID+"/pay", payKey, map[string]interface{}{"amount": 25})
+		assert.Equal(t, 200, resp.StatusCode)
+	}
+
+	var payment models.Payment
+	resp = send("GET", "/api/v1/payments/order/"+orders[0].ID, "", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, float64(25), payment.AmountPaid)
+}
+
+func TestPaymentReceipts(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Installment Reseller",
+		"email": fmt.Sprintf("installment-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Installment Product",
+		"sku":           fmt.Sprintf("INS-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{{"product_id": product.ID, "quantity": 1}},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+
+	// An unknown method is rejected
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 10, "method": "cheque"})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// A backdated bank transfer followed by cash
+	receivedAt := time.Now().AddDate(0, 0, -2).Truncate(time.Second)
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{
+		"amount":      30,
+		"method":      "bank_transfer",
+		"reference":   "TRF-001",
+		"received_at": receivedAt,
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var payment models.Payment
+	resp = send("POST", "/api/v1/payments/order/"+order.OrderNumber+"/pay", map[string]interface{}{"amount": 20, "notes": "cash at store"})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+
+	assert.Equal(t, "partially_paid", payment.Status)
+	assert.InDelta(t, 50, payment.AmountPaid, 0.001)
+	if assert.Len(t, payment.Receipts, 2) {
+		assert.Equal(t, models.PaymentBankTransfer, payment.Receipts[0].Method)
+		assert.Equal(t, "TRF-001", payment.Receipts[0].Reference)
+		assert.WithinDuration(t, receivedAt, payment.Receipts[0].ReceivedAt, time.Second)
+		assert.Equal(t, models.PaymentCash, payment.Receipts[1].Method)
+
+		var sum float64
+		for _, receipt := range payment.Receipts {
+			sum += receipt.Amount
+			assert.Equal(t, order.ID, receipt.OrderID)
+			assert.NotNil(t, receipt.TransactionID)
+			assert.NotEmpty(t, receipt.RecordedBy)
+		}
+		assert.InDelta(t, payment.AmountPaid, sum, 0.001)
+	}
+}
+
+func TestVoidPayment(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Void Reseller",
+		"email": fmt.Sprintf("void-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Void Product",
+		"sku":           fmt.Sprintf("VOID-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	var order models.Order
+	resp = send("POST", "/api/v1/orders", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"order_items": []map[string]interface{}{{"product_id": product.ID, "quantity": 1}},
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 40})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// The mistyped installment pays the order in full
+	var payment models.Payment
+	resp = send("POST", "/api/v1/payments/order/"+order.ID+"/pay", map[string]interface{}{"amount": 60})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "paid", payment.Status)
+	if !assert.Len(t, payment.Receipts, 2) {
+		return
+	}
+	mistake := payment.Receipts[1]
+
+	var before models.Balance
+	resp = send("GET", "/api/v1/balance", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&before))
+
+	// A reason is required
+	resp = send("POST", "/api/v1/payments/receipts/"+mistake.ID+"/void", map[string]interface{}{"reason": " "})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = send("POST", "/api/v1/payments/receipts/"+mistake.ID+"/void", map[string]interface{}{"reason": "Typed the wrong amount"})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "partially_paid", payment.Status)
+	assert.InDelta(t, 40, payment.AmountPaid, 0.001)
+
+	// The voided receipt stays in the history with its reversal
+	if assert.Len(t, payment.Receipts, 2) {
+		voided := payment.Receipts[1]
+		assert.Equal(t, mistake.ID, voided.ID)
+		assert.NotNil(t, voided.VoidedAt)
+		assert.Equal(t, "Typed the wrong amount", voided.VoidReason)
+		assert.NotNil(t, voided.ReversalTransactionID)
+	}
+
+	resp = send("GET", "/api/v1/orders/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+	assert.Equal(t, "partially_paid", order.PaymentStatus)
+
+	var after models.Balance
+	resp = send("GET", "/api/v1/balance", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&after))
+	assert.InDelta(t, before.CurrentBalance-60, after.CurrentBalance, 0.001)
+
+	var transactions []models.Transaction
+	resp = send("GET", "/api/v1/transactions", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&transactions))
+	reversed := false
+	for _, transaction := range transactions {
+		if transaction.Category == models.Reversal && transaction.PaymentID != nil && *transaction.PaymentID == payment.ID {
+			reversed = true
+			assert.Equal(t, models.CashOut, transaction.Type)
+			assert.InDelta(t, 60, transaction.Amount, 0.001)
+		}
+	}
+	assert.True(t, reversed)
+
+	// A receipt can only be voided once
+	resp = send("POST", "/api/v1/payments/receipts/"+mistake.ID+"/void", map[string]interface{}{"reason": "Again"})
+	assert.Equal(t, 409, resp.StatusCode)
+
+	// Cancelling the order takes back only the installment still standing,
+	// the voided one was reversed already
+	resp = send("PATCH", "/api/v1/orders/"+order.ID+"/cancel", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp = send("GET", "/api/v1/balance", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&after))
+	assert.InDelta(t, before.CurrentBalance-100, after.CurrentBalance, 0.001)
+
+	resp = send("GET", "/api/v1/payments/order/"+order.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "cancelled", payment.Status)
+	if assert.Len(t, payment.Receipts, 2) {
+		assert.Equal(t, "Typed the wrong amount", payment.Receipts[1].VoidReason)
+		assert.Equal(t, "Order cancelled", payment.Receipts[0].VoidReason)
+	}
+}
+
+func TestResellerCreditWallet(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  "Credit Reseller",
+		"email": fmt.Sprintf("credit-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Credit Product",
+		"sku":           fmt.Sprintf("CRD-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	createOrder := func() models.Order {
+		var order models.Order
+		resp := send("POST", "/api/v1/orders", map[string]interface{}{
+			"reseller_id": reseller.ID,
+			"order_items": []map[string]interface{}{{"product_id": product.ID, "quantity": 1}},
+		})
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+		return order
+	}
+	creditBalance := func() float64 {
+		var balance models.CreditBalance
+		resp := send("GET", "/api/v1/resellers/"+reseller.ID+"/credit", nil)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&balance))
+		return balance.Balance
+	}
+
+	// Deposits cannot be paid from credit
+	resp = send("POST", "/api/v1/resellers/"+reseller.ID+"/credit/deposits", map[string]interface{}{"amount": 100, "method": "credit"})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = send("POST", "/api/v1/resellers/"+reseller.ID+"/credit/deposits", map[string]interface{}{
+		"amount":    100,
+		"method":    "bank_transfer",
+		"reference": "DEP-001",
+	})
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.InDelta(t, 100, creditBalance(), 0.001)
+
+	// An overpayment settles the order and the rest goes to credit
+	first := createOrder()
+	var payment models.Payment
+	resp = send("POST", "/api/v1/payments/order/"+first.ID+"/pay", map[string]interface{}{"amount": first.TotalAmount + 50})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "paid", payment.Status)
+	assert.InDelta(t, first.TotalAmount, payment.AmountPaid, 0.001)
+	assert.InDelta(t, 150, creditBalance(), 0.001)
+
+	resp = send("POST", "/api/v1/payments/order/"+first.ID+"/pay", map[string]interface{}{"amount": 10})
+	assert.Equal(t, 409, resp.StatusCode)
+
+	// Credit pays an order without bringing in cash
+	second := createOrder()
+	resp = send("POST", "/api/v1/payments/order/"+second.ID+"/pay", map[string]interface{}{"amount": second.TotalAmount + 500, "method": "credit"})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = send("POST", "/api/v1/payments/order/"+second.ID+"/pay", map[string]interface{}{"amount": 100, "method": "credit"})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.InDelta(t, 50, creditBalance(), 0.001)
+	if assert.Len(t, payment.Receipts, 1) {
+		assert.Nil(t, payment.Receipts[0].TransactionID)
+
+		// Voiding a credit payment gives the credit back
+		resp = send("POST", "/api/v1/payments/receipts/"+payment.Receipts[0].ID+"/void", map[string]interface{}{"reason": "Wrong order"})
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.InDelta(t, 150, creditBalance(), 0.001)
+	}
+
+	// More than the available credit is rejected
+	resp = send("POST", "/api/v1/payments/order/"+second.ID+"/pay", map[string]interface{}{"amount": 100, "method": "credit"})
+	assert.Equal(t, 200, resp.StatusCode)
+	third := createOrder()
+	resp = send("POST", "/api/v1/payments/order/"+third.ID+"/pay", map[string]interface{}{"amount": 100, "method": "credit"})
+	assert.Equal(t, 409, resp.StatusCode)
+
+	// Cancelling an order paid from credit gives the credit back
+	resp = send("PATCH", "/api/v1/orders/"+second.ID+"/cancel", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.InDelta(t, 150, creditBalance(), 0.001)
+
+	// Cash paid for a cancelled order can be kept as credit instead of refunded
+	resp = send("POST", "/api/v1/payments/order/"+third.ID+"/pay", map[string]interface{}{"amount": 60})
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// A refund pays cash out, which cashiers may not do
+	cashier := sender(t, app, authenticateWithRole(t, app, token, models.RoleCashier, ""))
+	resp = cashier("PATCH", "/api/v1/orders/"+third.ID+"/cancel", nil)
+	assert.Equal(t, 403, resp.StatusCode)
+	resp = cashier("PATCH", "/api/v1/orders/"+third.ID+"/status", map[string]interface{}{"status": "cancelled"})
+	assert.Equal(t, 403, resp.StatusCode)
+
+	var before models.Balance
+	resp = send("GET", "/api/v1/balance", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&before))
+
+	resp = send("PATCH", "/api/v1/orders/"+third.ID+"/cancel?settlement=cash", nil)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = cashier("PATCH", "/api/v1/orders/"+third.ID+"/cancel?settlement=credit", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.InDelta(t, 210, creditBalance(), 0.001)
+
+	var after models.Balance
+	resp = send("GET", "/api/v1/balance", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&after))
+	assert.InDelta(t, before.CurrentBalance, after.CurrentBalance, 0.001)
+
+	var statement models.CreditStatement
+	resp = send("GET", "/api/v1/resellers/"+reseller.ID+"/credit/statement", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&statement))
+	assert.InDelta(t, 210, statement.Balance, 0.001)
+
+	var types []models.CreditEntryType
+	for _, entry := range statement.Entries {
+		types = append(types, entry.Type)
+	}
+	assert.Equal(t, []models.CreditEntryType{
+		models.CreditDeposit,
+		models.CreditOverpayment,
+		models.CreditPayment,
+		models.CreditReversal,
+		models.CreditPayment,
+		models.CreditCancellation,
+		models.CreditCancellation,
+	}, types)
+	if assert.NotEmpty(t, statement.Entries) {
+		assert.InDelta(t, statement.Balance, statement.Entries[len(statement.Entries)-1].Balance, 0.001)
+	}
+}
+
+func TestAllocatePaymentAcrossOrders(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	createReseller := func(name, email string) models.Reseller {
+		var reseller models.Reseller
+		resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+			"name":  name,
+			"email": fmt.Sprintf("%s-%d@example.com", email, suffix),
+		})
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+		return reseller
+	}
+	reseller := createReseller("Allocation Reseller", "allocation")
+	other := createReseller("Other Reseller", "allocation-other")
+
+	var product models.Product
+	resp := send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Allocation Product",
+		"sku":           fmt.Sprintf("ALC-%d", suffix),
+		"price":         100,
+		"current_stock": 20,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	createOrder := func(resellerID string, dueInDays int) models.Order {
+		var order models.Order
+		resp := send("POST", "/api/v1/orders", map[string]interface{}{
+			"reseller_id": resellerID,
+			"due_date":    time.Now().AddDate(0, 0, dueInDays),
+			"order_items": []map[string]interface{}{{"product_id": product.ID, "quantity": 1}},
+		})
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+		return order
+	}
+	later := createOrder(reseller.ID, 10)
+	oldest := createOrder(reseller.ID, -5)
+	older := createOrder(reseller.ID, -1)
+	foreign := createOrder(other.ID, 3)
+
+	// Oldest due first by default
+	var allocation models.PaymentAllocation
+	amount := oldest.TotalAmount + older.TotalAmount + 20
+	resp = send("POST", "/api/v1/payments/allocate", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"amount":      amount,
+		"method":      "bank_transfer",
+		"reference":   "TRF-ALLOC",
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&allocation))
+	assert.InDelta(t, amount, allocation.Allocated, 0.001)
+	assert.InDelta(t, 0, allocation.Credited, 0.001)
+	if assert.Len(t, allocation.Receipts, 3) {
+		assert.Equal(t, oldest.ID, allocation.Receipts[0].OrderID)
+		assert.Equal(t, older.ID, allocation.Receipts[1].OrderID)
+		assert.Equal(t, later.ID, allocation.Receipts[2].OrderID)
+		assert.InDelta(t, 20, allocation.Receipts[2].Amount, 0.001)
+		for _, receipt := range allocation.Receipts {
+			if assert.NotNil(t, receipt.TransactionID) {
+				assert.Equal(t, allocation.TransactionID, *receipt.TransactionID)
+			}
+		}
+	}
+
+	for id, status := range map[string]string{oldest.ID: "paid", older.ID: "paid", later.ID: "partially_paid"} {
+		var payment models.Payment
+		resp = send("GET", "/api/v1/payments/order/"+id, nil)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+		assert.Equal(t, status, payment.Status)
+	}
+
+	var transactions []models.Transaction
+	resp = send("GET", "/api/v1/transactions", nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&transactions))
+	found := false
+	for _, transaction := range transactions {
+		if transaction.ID == allocation.TransactionID {
+			found = true
+			assert.Equal(t, models.CashIn, transaction.Type)
+			assert.InDelta(t, amount, transaction.Amount, 0.001)
+		}
+	}
+	assert.True(t, found)
+
+	// Orders of other resellers and splits above the amount are rejected
+	resp = send("POST", "/api/v1/payments/allocate", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"amount":      50,
+		"allocations": []map[string]interface{}{{"order_id": foreign.ID, "amount": 50}},
+	})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp = send("POST", "/api/v1/payments/allocate", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"amount":      5,
+		"allocations": []map[string]interface{}{{"order_id": later.ID, "amount": 10}},
+	})
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// An explicit split leaves the rest as credit
+	resp = send("POST", "/api/v1/payments/allocate", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"amount":      50,
+		"allocations": []map[string]interface{}{{"order_id": later.OrderNumber, "amount": 10}},
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&allocation))
+	assert.InDelta(t, 10, allocation.Allocated, 0.001)
+	assert.InDelta(t, 40, allocation.Credited, 0.001)
+
+	creditBalance := func() float64 {
+		var balance models.CreditBalance
+		resp := send("GET", "/api/v1/resellers/"+reseller.ID+"/credit", nil)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&balance))
+		return balance.Balance
+	}
+	cashBalance := func() float64 {
+		var balance models.Balance
+		resp := send("GET", "/api/v1/balance", nil)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&balance))
+		return balance.CurrentBalance
+	}
+	assert.InDelta(t, 40, creditBalance(), 0.001)
+
+	// Cancelling an order paid by the shared transfer refunds only its share
+	before := cashBalance()
+	resp = send("PATCH", "/api/v1/orders/"+older.ID+"/cancel", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.InDelta(t, before-older.TotalAmount, cashBalance(), 0.001)
+	assert.InDelta(t, 40, creditBalance(), 0.001)
+
+	var payment models.Payment
+	resp = send("GET", "/api/v1/payments/order/"+older.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "cancelled", payment.Status)
+	if assert.Len(t, payment.Receipts, 1) {
+		assert.True(t, payment.Receipts[0].IsVoided())
+		assert.NotNil(t, payment.Receipts[0].ReversalTransactionID)
+	}
+
+	// Or moves its share to the reseller's credit, keeping the cash
+	before = cashBalance()
+	resp = send("PATCH", "/api/v1/orders/"+later.ID+"/cancel?settlement=credit", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.InDelta(t, before, cashBalance(), 0.001)
+	assert.InDelta(t, 70, creditBalance(), 0.001)
+
+	// Cancelled orders cannot be allocated to
+	resp = send("POST", "/api/v1/payments/allocate", map[string]interface{}{
+		"reseller_id": reseller.ID,
+		"amount":      10,
+		"allocations": []map[string]interface{}{{"order_id": later.ID, "amount": 10}},
+	})
+	assert.Equal(t, 409, resp.StatusCode)
+	assert.InDelta(t, before, cashBalance(), 0.001)
+}
+
+func TestBankStatementImport(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	token := authenticate(t, app)
+	suffix := time.Now().UnixNano()
+
+	send := sender(t, app, token)
+
+	upload := func(bank, csv string) *http.Response {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		assert.NoError(t, form.WriteField("bank", bank))
+		file, err := form.CreateFormFile("file", "statement.csv")
+		assert.NoError(t, err)
+		_, err = io.WriteString(file, csv)
+		assert.NoError(t, err)
+		assert.NoError(t, form.Close())
+
+		req := httptest.NewRequest("POST", "/api/v1/bank-statements", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resellerName := fmt.Sprintf("Toko Bank %d", suffix)
+	var reseller models.Reseller
+	resp := send("POST", "/api/v1/resellers", map[string]interface{}{
+		"name":  resellerName,
+		"email": fmt.Sprintf("bank-%d@example.com", suffix),
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reseller))
+
+	var product models.Product
+	resp = send("POST", "/api/v1/products", map[string]interface{}{
+		"name":          "Bank Product",
+		"sku":           fmt.Sprintf("BNK-%d", suffix),
+		"price":         100,
+		"current_stock": 10,
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&product))
+
+	createOrder := func(quantity int) models.Order {
+		var order models.Order
+		resp := send("POST", "/api/v1/orders", map[string]interface{}{
+			"reseller_id": reseller.ID,
+			"order_items": []map[string]interface{}{{"product_id": product.ID, "quantity": quantity}},
+		})
+		assert.Equal(t, 201, resp.StatusCode)
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&order))
+		return order
+	}
+	byNumber := createOrder(1)
+	byNameAndAmount := createOrder(2)
+
+	resp = upload("unknown", "date,description,amount\n")
+	assert.Equal(t, 400, resp.StatusCode)
+
+	today := time.Now().Format("2006-01-02")
+	csv := fmt.Sprintf("date,description,amount,reference,type\n"+
+		"%s,TRF %s PAYMENT,%.2f,REF-1,credit\n"+
+		"%s,TRANSFER FROM %s,%.2f,REF-2,credit\n"+
+		"%s,ATM WITHDRAWAL,50.00,REF-3,debit\n"+
+		"%s,UNKNOWN SENDER %d,12.34,REF-4,credit\n",
+		today, strings.ReplaceAll(byNumber.OrderNumber, "-", ""), byNumber.TotalAmount-10,
+		today, strings.ToUpper(resellerName), byNameAndAmount.TotalAmount,
+		today, today, suffix)
+
+	var statement models.BankStatement
+	resp = upload("generic", csv)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&statement))
+
+	// The debit is left out
+	if !assert.Len(t, statement.Lines, 3) {
+		return
+	}
+	lines := map[string]models.BankStatementLine{}
+	for _, line := range statement.Lines {
+		lines[line.Reference] = line
+	}
+	if assert.NotNil(t, lines["REF-1"].OrderID) {
+		assert.Equal(t, byNumber.ID, *lines["REF-1"].OrderID)
+		assert.Equal(t, models.BankLineProposed, lines["REF-1"].Status)
+	}
+	if assert.NotNil(t, lines["REF-2"].OrderID) {
+		assert.Equal(t, byNameAndAmount.ID, *lines["REF-2"].OrderID)
+		assert.Equal(t, "amount, reseller name", lines["REF-2"].MatchReason)
+	}
+	assert.Equal(t, models.BankLineUnmatched, lines["REF-4"].Status)
+	assert.Nil(t, lines["REF-4"].OrderID)
+
+	// Importing the statement again adds nothing to confirm twice
+	resp = upload("generic", csv)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	// An overlapping statement only adds the transfers not seen before
+	var overlapping models.BankStatement
+	resp = upload("generic", csv+fmt.Sprintf("%s,UNKNOWN SENDER %d,12.34,REF-4,credit\n", today, suffix))
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&overlapping))
+	assert.Equal(t, 3, overlapping.DuplicateLines)
+	if assert.Len(t, overlapping.Lines, 1) {
+		assert.Equal(t, "REF-4", overlapping.Lines[0].Reference)
+	}
+
+	var results []models.BankMatchResult
+	resp = send("POST", "/api/v1/bank-statements/"+statement.ID+"/matches", map[string]interface{}{
+		"decisions": []map[string]interface{}{
+			{"line_id": lines["REF-1"].ID, "action": "confirm"},
+			{"line_id": lines["REF-2"].ID, "action": "confirm"},
+			{"line_id": lines["REF-4"].ID, "action": "confirm"},
+			{"line_id": lines["REF-1"].ID, "action": "confirm"},
+		},
+	})
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, models.BankLineConfirmed, results[0].Status)
+		assert.NotNil(t, results[0].ReceiptID)
+		assert.Equal(t, models.BankLineConfirmed, results[1].Status)
+		// Unmatched lines need an order and lines are decided once
+		assert.NotEmpty(t, results[2].Error)
+		assert.NotEmpty(t, results[3].Error)
+	}
+
+	resp = send("POST", "/api/v1/bank-statements/"+statement.ID+"/matches", map[string]interface{}{
+		"decisions": []map[string]interface{}{{"line_id": lines["REF-4"].ID, "action": "reject"}},
+	})
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, models.BankLineRejected, results[0].Status)
+	}
+
+	// Confirmed lines are recorded as bank transfers
+	var payment models.Payment
+	resp = send("GET", "/api/v1/payments/order/"+byNumber.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "partially_paid", payment.Status)
+	if assert.Len(t, payment.Receipts, 1) {
+		assert.Equal(t, models.PaymentBankTransfer, payment.Receipts[0].Method)
+		assert.Equal(t, "REF-1", payment.Receipts[0].Reference)
+	}
+
+	resp = send("GET", "/api/v1/payments/order/"+byNameAndAmount.ID, nil)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&payment))
+	assert.Equal(t, "paid", payment.Status)
+}